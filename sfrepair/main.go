@@ -0,0 +1,65 @@
+// sfrepair checks WAV and AIFF files for headers that disagree with their contents and fixes them.
+//
+// Usage:
+//
+//	sfrepair [-n] [-v] [-d] [-extend] [-o output] file...
+//
+// With -d each file is diagnosed instead: its format, libsndfile's complaints about it, the header problems found and where its chunks are. Nothing is written. Otherwise files are repaired in place unless -o is given, which is only allowed with a single file. With -n problems are reported but nothing is written. With -extend, unrecognised bytes after the audio are taken to be audio the header didn't count, rather than garbage to be dropped. sfrepair exits with status 1 if any file couldn't be checked or repaired.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/mkb218/gosndfile/sndfile"
+)
+
+func main() {
+	var opts sndfile.RepairOptions
+	verbose := flag.Bool("v", false, "print libsndfile's log for each file")
+	diagnose := flag.Bool("d", false, "print a diagnosis of each file instead of repairing it")
+	flag.BoolVar(&opts.DryRun, "n", false, "report problems without writing anything")
+	flag.BoolVar(&opts.ExtendData, "extend", false, "treat bytes after the audio as audio")
+	flag.StringVar(&opts.Output, "o", "", "write the repaired file here instead of in place")
+	flag.Parse()
+	if flag.NArg() == 0 || (opts.Output != "" && flag.NArg() > 1) {
+		fmt.Fprintln(os.Stderr, "usage: sfrepair [-n] [-v] [-d] [-extend] [-o output] file...")
+		os.Exit(2)
+	}
+
+	status := 0
+	for _, name := range flag.Args() {
+		if *diagnose {
+			d, err := sndfile.Diagnose(name)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+				status = 1
+				continue
+			}
+			fmt.Print(d)
+			continue
+		}
+		report, err := sndfile.Repair(name, &opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			status = 1
+			continue
+		}
+		switch {
+		case report.Problems == 0:
+			fmt.Printf("%s: ok, %d frames\n", name, report.Frames)
+		case report.Written:
+			fmt.Printf("%s: repaired %v, %d frames\n", name, report.Problems, report.Frames)
+		default:
+			fmt.Printf("%s: found %v\n", name, report.Problems)
+		}
+		for _, w := range report.Warnings {
+			fmt.Printf("\tlibsndfile: %s\n", w)
+		}
+		if *verbose {
+			fmt.Print(report.Log)
+		}
+	}
+	os.Exit(status)
+}
@@ -0,0 +1,228 @@
+package sndfile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/cmplx"
+)
+
+// FilterType is the response of a Biquad.
+type FilterType int
+
+const (
+	LowPass FilterType = iota
+	HighPass
+	BandPass // constant 0 dB peak gain
+	Notch
+	Peaking
+	LowShelf
+	HighShelf
+)
+
+func (t FilterType) String() string {
+	switch t {
+	case LowPass:
+		return "low pass"
+	case HighPass:
+		return "high pass"
+	case BandPass:
+		return "band pass"
+	case Notch:
+		return "notch"
+	case Peaking:
+		return "peaking"
+	case LowShelf:
+		return "low shelf"
+	case HighShelf:
+		return "high shelf"
+	}
+	return fmt.Sprintf("FilterType(%d)", int(t))
+}
+
+// A Biquad is a second order filter section, with its coefficients normalised so that a0 is 1:
+//
+//	y[n] = B0 x[n] + B1 x[n-1] + B2 x[n-2] - A1 y[n-1] - A2 y[n-2]
+//
+// A first order section has B2 and A2 zero.
+type Biquad struct {
+	B0, B1, B2 float64
+	A1, A2     float64
+}
+
+// NewBiquad designs a filter for audio at samplerate as Robert Bristow-Johnson's Audio EQ Cookbook gives it. freq is the cutoff, centre or shelf midpoint frequency in Hz, q its Q, and gainDB the gain of a peaking or shelf filter, ignored by the others. For the shelves, a Q of 1/√2 gives the steepest slope without a bump.
+func NewBiquad(t FilterType, samplerate, freq, q, gainDB float64) (Biquad, error) {
+	if freq <= 0 || freq >= samplerate/2 {
+		return Biquad{}, fmt.Errorf("NewBiquad: frequency %g Hz outside 0 to %g Hz", freq, samplerate/2)
+	}
+	if q <= 0 {
+		return Biquad{}, errors.New("NewBiquad: Q must be positive")
+	}
+	w := 2 * math.Pi * freq / samplerate
+	cos, alpha := math.Cos(w), math.Sin(w)/(2*q)
+	a := math.Pow(10, gainDB/40)
+	var b0, b1, b2, a0, a1, a2 float64
+	switch t {
+	case LowPass:
+		b0, b1, b2 = (1-cos)/2, 1-cos, (1-cos)/2
+		a0, a1, a2 = 1+alpha, -2*cos, 1-alpha
+	case HighPass:
+		b0, b1, b2 = (1+cos)/2, -(1 + cos), (1+cos)/2
+		a0, a1, a2 = 1+alpha, -2*cos, 1-alpha
+	case BandPass:
+		b0, b1, b2 = alpha, 0, -alpha
+		a0, a1, a2 = 1+alpha, -2*cos, 1-alpha
+	case Notch:
+		b0, b1, b2 = 1, -2*cos, 1
+		a0, a1, a2 = 1+alpha, -2*cos, 1-alpha
+	case Peaking:
+		b0, b1, b2 = 1+alpha*a, -2*cos, 1-alpha*a
+		a0, a1, a2 = 1+alpha/a, -2*cos, 1-alpha/a
+	case LowShelf:
+		s := 2 * math.Sqrt(a) * alpha
+		b0, b1, b2 = a*((a+1)-(a-1)*cos+s), 2*a*((a-1)-(a+1)*cos), a*((a+1)-(a-1)*cos-s)
+		a0, a1, a2 = (a+1)+(a-1)*cos+s, -2*((a-1)+(a+1)*cos), (a+1)+(a-1)*cos-s
+	case HighShelf:
+		s := 2 * math.Sqrt(a) * alpha
+		b0, b1, b2 = a*((a+1)+(a-1)*cos+s), -2*a*((a-1)+(a+1)*cos), a*((a+1)+(a-1)*cos-s)
+		a0, a1, a2 = (a+1)-(a-1)*cos+s, 2*((a-1)-(a+1)*cos), (a+1)-(a-1)*cos-s
+	default:
+		return Biquad{}, fmt.Errorf("NewBiquad: unknown filter type %v", t)
+	}
+	return Biquad{b0 / a0, b1 / a0, b2 / a0, a1 / a0, a2 / a0}, nil
+}
+
+// firstOrder designs a first order low or high pass section by the bilinear transform.
+func firstOrder(t FilterType, samplerate, freq float64) Biquad {
+	k := math.Tan(math.Pi * freq / samplerate)
+	a1 := (k - 1) / (k + 1)
+	if t == HighPass {
+		return Biquad{B0: 1 / (1 + k), B1: -1 / (1 + k), A1: a1}
+	}
+	return Biquad{B0: k / (1 + k), B1: k / (1 + k), A1: a1}
+}
+
+// Butterworth designs a low or high pass Butterworth filter of the given order as a cascade of sections, second order but for a first order section when the order is odd. The response is 3 dB down at freq.
+func Butterworth(t FilterType, order int, samplerate, freq float64) ([]Biquad, error) {
+	if t != LowPass && t != HighPass {
+		return nil, fmt.Errorf("Butterworth: can't make a %v filter", t)
+	}
+	if order < 1 {
+		return nil, errors.New("Butterworth: order must be at least 1")
+	}
+	if freq <= 0 || freq >= samplerate/2 {
+		return nil, fmt.Errorf("Butterworth: frequency %g Hz outside 0 to %g Hz", freq, samplerate/2)
+	}
+	var sections []Biquad
+	// each conjugate pair of poles, spaced evenly round the unit circle in the left half of the s-plane, gives a section with the Q of their angle
+	for k := 0; k < order/2; k++ {
+		q := -1 / (2 * math.Cos(math.Pi*float64(2*k+order+1)/float64(2*order)))
+		b, err := NewBiquad(t, samplerate, freq, q, 0)
+		if err != nil {
+			return nil, err
+		}
+		sections = append(sections, b)
+	}
+	if order%2 == 1 {
+		sections = append(sections, firstOrder(t, samplerate, freq))
+	}
+	return sections, nil
+}
+
+// LinkwitzRiley designs a low or high pass Linkwitz-Riley crossover filter of the given even order, as two Butterworth filters of half the order in cascade. The response is 6 dB down at freq, and the low and high pass filters of the same order and frequency sum to a flat magnitude response. At orders 2, 6, 10 and so on the two are 180° apart and would cancel at freq, so there the high pass is inverted, as is usual for these crossovers.
+func LinkwitzRiley(t FilterType, order int, samplerate, freq float64) ([]Biquad, error) {
+	if order < 2 || order%2 != 0 {
+		return nil, fmt.Errorf("LinkwitzRiley: order %d isn't even", order)
+	}
+	b, err := Butterworth(t, order/2, samplerate, freq)
+	if err != nil {
+		return nil, err
+	}
+	sections := append(b, b...)
+	if t == HighPass && order%4 == 2 {
+		s := &sections[0]
+		s.B0, s.B1, s.B2 = -s.B0, -s.B1, -s.B2
+	}
+	return sections, nil
+}
+
+// Response returns the complex response of the section at freq, for audio at samplerate.
+func (b Biquad) Response(freq, samplerate float64) complex128 {
+	z := cmplx.Exp(complex(0, -2*math.Pi*freq/samplerate)) // z^-1
+	num := complex(b.B0, 0) + complex(b.B1, 0)*z + complex(b.B2, 0)*z*z
+	den := 1 + complex(b.A1, 0)*z + complex(b.A2, 0)*z*z
+	return num / den
+}
+
+// A Filter runs audio through a cascade of Biquads, keeping the state of each channel apart.
+type Filter struct {
+	sections []Biquad
+	channels int
+	state    [][2]float64 // per channel and section, transposed direct form II
+}
+
+// NewFilter returns a Filter passing audio with the given number of channels through sections in order.
+func NewFilter(channels int, sections ...Biquad) (*Filter, error) {
+	if channels < 1 {
+		return nil, errors.New("NewFilter: no channels")
+	}
+	return &Filter{
+		sections: append([]Biquad(nil), sections...),
+		channels: channels,
+		state:    make([][2]float64, channels*len(sections)),
+	}, nil
+}
+
+// Process filters the next frames of the audio in place.
+func (f *Filter) Process(b []float64) {
+	for i, x := range b[:len(b)-len(b)%f.channels] {
+		st := f.state[(i%f.channels)*len(f.sections):]
+		for k, s := range f.sections {
+			y := s.B0*x + st[k][0]
+			st[k][0] = s.B1*x - s.A1*y + st[k][1]
+			st[k][1] = s.B2*x - s.A2*y
+			x = y
+		}
+		b[i] = x
+	}
+}
+
+// Reset clears the filter's memory of the audio so far.
+func (f *Filter) Reset() {
+	for i := range f.state {
+		f.state[i] = [2]float64{}
+	}
+}
+
+// Response returns the gain in dB of the cascade at freq, for audio at samplerate.
+func (f *Filter) Response(freq, samplerate float64) float64 {
+	h := complex(1, 0)
+	for _, s := range f.sections {
+		h *= s.Response(freq, samplerate)
+	}
+	return 20 * math.Log10(cmplx.Abs(h))
+}
+
+// FilterStage returns a Processor that runs audio through sections, which were designed for samplerate, with a Filter made for each Source it is connected to. Connecting it to audio at another rate is an error.
+func FilterStage(samplerate int, sections ...Biquad) Processor {
+	return processorFunc(func(in Source) (Source, error) {
+		if in.Format().Samplerate != samplerate {
+			return nil, fmt.Errorf("FilterStage: filter for %d Hz given %v", samplerate, in.Format())
+		}
+		f, err := NewFilter(in.Format().Channels, sections...)
+		if err != nil {
+			return nil, err
+		}
+		return Blocks(in.Format(), f).Connect(in)
+	})
+}
+
+// ApplyFilter runs the audio of src from its current position through sections, which are designed for its sample rate, and writes it to dst, which must be open for writing with the same rate and channel count. Neither file is closed.
+func ApplyFilter(ctx context.Context, dst, src *File, sections ...Biquad) error {
+	in, err := Chain(NewFileSource(src), FilterStage(int(src.Format.Samplerate), sections...))
+	if err != nil {
+		return err
+	}
+	return Run(ctx, 0, Route{in, NewFileSink(dst)})
+}
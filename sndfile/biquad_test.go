@@ -0,0 +1,150 @@
+package sndfile
+
+import (
+	"context"
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+func TestBiquad(t *testing.T) {
+	const rate = 48000
+	db := func(sections []Biquad, freq float64) float64 {
+		f, err := NewFilter(1, sections...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f.Response(freq, rate)
+	}
+	one := func(ft FilterType, freq, q, gain float64) []Biquad {
+		b, err := NewBiquad(ft, rate, freq, q, gain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return []Biquad{b}
+	}
+	for _, c := range []struct {
+		name     string
+		sections []Biquad
+		freq     float64
+		want     float64
+	}{
+		{"low pass at cutoff", one(LowPass, 1000, math.Sqrt(0.5), 0), 1000, -3.0103},
+		{"low pass well below cutoff", one(LowPass, 1000, math.Sqrt(0.5), 0), 10, 0},
+		{"high pass at cutoff", one(HighPass, 1000, math.Sqrt(0.5), 0), 1000, -3.0103},
+		{"band pass at centre", one(BandPass, 1000, 2, 0), 1000, 0},
+		{"peaking at centre", one(Peaking, 1000, 1, 6), 1000, 6},
+		{"peaking far off", one(Peaking, 1000, 1, 6), 20000, 0},
+		{"low shelf at DC", one(LowShelf, 200, math.Sqrt(0.5), -9), 1, -9},
+		{"low shelf at midpoint", one(LowShelf, 200, math.Sqrt(0.5), -9), 200, -4.5},
+		{"high shelf near Nyquist", one(HighShelf, 5000, math.Sqrt(0.5), 4), 23999, 4},
+	} {
+		if got := db(c.sections, c.freq); math.Abs(got-c.want) > 0.05 {
+			t.Errorf("%s: %g dB, expected %g", c.name, got, c.want)
+		}
+	}
+	if got := db(one(Notch, 1000, 2, 0), 1000); got > -100 {
+		t.Errorf("notch passes %g dB at its centre", got)
+	}
+
+	// Butterworth filters are 3 dB down at the cutoff and fall 6 dB an octave per order beyond it, odd orders too
+	for _, order := range []int{1, 2, 3, 4, 8} {
+		lp, err := Butterworth(LowPass, order, rate, 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lp) != (order+1)/2 {
+			t.Errorf("order %d in %d sections", order, len(lp))
+		}
+		want := -10 * math.Log10(1+math.Pow(4, float64(order))) // at 2 kHz, before the bilinear warping
+		if got := db(lp, 1000); math.Abs(got+3.0103) > 0.01 {
+			t.Errorf("order %d: %g dB at the cutoff", order, got)
+		}
+		if got := db(lp, 2000); math.Abs(got-want) > 0.5 {
+			t.Errorf("order %d: %g dB an octave up, expected about %g", order, got, want)
+		}
+	}
+
+	// Linkwitz-Riley pairs are 6 dB down at the crossover and sum flat, whether or not the order is a multiple of 4
+	var lp []Biquad
+	for _, order := range []int{2, 4, 6, 8} {
+		lp, _ = LinkwitzRiley(LowPass, order, rate, 2000)
+		hp, _ := LinkwitzRiley(HighPass, order, rate, 2000)
+		if got := db(lp, 2000); math.Abs(got+6.0206) > 0.01 {
+			t.Errorf("LR%d low pass is %g dB at the crossover", order, got)
+		}
+		if got := db(hp, 2000); math.Abs(got+6.0206) > 0.01 {
+			t.Errorf("LR%d high pass is %g dB at the crossover", order, got)
+		}
+		for _, freq := range []float64{50, 1000, 2000, 3000, 15000} {
+			h := complex(1, 0)
+			for _, s := range lp {
+				h *= s.Response(freq, rate)
+			}
+			g := complex(1, 0)
+			for _, s := range hp {
+				g *= s.Response(freq, rate)
+			}
+			if m := cmplx.Abs(h + g); math.Abs(m-1) > 1e-9 {
+				t.Errorf("LR%d pair sums to %g at %g Hz", order, m, freq)
+			}
+		}
+	}
+
+	if _, err := NewBiquad(LowPass, rate, 30000, 1, 0); err == nil {
+		t.Error("expected an error for a cutoff above Nyquist")
+	}
+	if _, err := LinkwitzRiley(LowPass, 3, rate, 1000); err == nil {
+		t.Error("expected an error for an odd Linkwitz-Riley order")
+	}
+	if _, err := NewFilter(0, lp...); err == nil {
+		t.Error("expected an error for a filter with no channels")
+	}
+}
+
+func TestApplyFilter(t *testing.T) {
+	// a 1 kHz tone on the left and silence on the right, through an 8th order high pass at 4 kHz
+	i := Info{Samplerate: 48000, Channels: 2, Format: SF_FORMAT_WAV | SF_FORMAT_FLOAT}
+	writeFloats(t, "filtersrc.wav", i, tone(48000, 2, -6, 0), nil)
+	src, err := Open("filtersrc.wav", Read, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	dst, err := Open("filterdst.wav", Write, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hp, _ := Butterworth(HighPass, 8, 48000, 4000)
+	err = ApplyFilter(context.Background(), dst, src, hp...)
+	dst.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dst, err = Open("filterdst.wav", Read, &i); err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	b := make([]float64, 2*i.Frames)
+	dst.ReadFrames(b)
+	hpf, err := NewFilter(1, hp...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := 0.5 * math.Pow(10, hpf.Response(1000, 48000)/20)
+	var peak float64
+	for n := 24000; n < 48000; n++ {
+		peak = math.Max(peak, math.Abs(b[2*n]))
+		if b[2*n+1] != 0 {
+			t.Fatalf("silent channel picked up %g at frame %d", b[2*n+1], n)
+		}
+	}
+	if math.Abs(peak-want)/want > 0.01 {
+		t.Errorf("filtered tone peaks at %g, expected %g", peak, want)
+	}
+
+	src.Seek(0, Set)
+	if _, err = Chain(NewFileSource(src), FilterStage(44100, hp...)); err == nil {
+		t.Error("expected an error filtering 48 kHz audio with a 44.1 kHz filter")
+	}
+}
@@ -0,0 +1,155 @@
+package sndfile
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+)
+
+// A few operations (raw remuxing of ADPCM, header recovery) need to look at a file's chunks directly rather than through libsndfile.
+
+type container int
+
+const (
+	riffContainer container = iota + 1
+	rf64Container
+	w64Container
+	aiffContainer
+	cafContainer
+)
+
+type chunk struct {
+	id   string
+	pos  int64 // offset of the chunk header
+	data int64 // offset of the chunk body
+	size int64 // size of the body according to the header. For RF64 the ds64 sizes have been applied.
+}
+
+type chunkFile struct {
+	kind   container
+	order  binary.ByteOrder
+	chunks []chunk
+	length int64
+	clean  bool // the chunks exactly cover the file, so the header was written after the audio
+}
+
+func (c *chunkFile) find(id string) *chunk {
+	for i := range c.chunks {
+		if c.chunks[i].id == id {
+			return &c.chunks[i]
+		}
+	}
+	return nil
+}
+
+func printableID(id []byte) bool {
+	for _, b := range id {
+		if b < 0x20 || b > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// scanChunks lists the chunks of a RIFF, RIFX, RF64, W64, AIFF or CAF file. It stops at the first chunk that doesn't look valid or runs past the end of the file, so a truncated file or a file whose header doesn't match its contents yields the chunks up to that point with clean false. The last chunk listed may run past the end of the file.
+func scanChunks(r io.ReadSeeker) (c *chunkFile, err error) {
+	c = new(chunkFile)
+	if c.length, err = r.Seek(0, os.SEEK_END); err != nil {
+		return nil, err
+	}
+	if _, err = r.Seek(0, os.SEEK_SET); err != nil {
+		return nil, err
+	}
+	var hdr [12]byte
+	if _, err = io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	var pos int64 = 12
+	switch string(hdr[:4]) {
+	case "RIFF":
+		c.kind, c.order = riffContainer, binary.LittleEndian
+	case "RIFX":
+		c.kind, c.order = riffContainer, binary.BigEndian
+	case "RF64":
+		c.kind, c.order = rf64Container, binary.LittleEndian
+	case "riff":
+		// W64 chunks are 16 byte GUIDs (beginning with the RIFF chunk id) and 64 bit sizes that include the chunk header, padded to 8 bytes
+		c.kind, c.order = w64Container, binary.LittleEndian
+		pos = 40
+	case "FORM":
+		c.kind, c.order = aiffContainer, binary.BigEndian
+	case "caff":
+		c.kind, c.order = cafContainer, binary.BigEndian
+		pos = 8
+	default:
+		return nil, errors.New("not a RIFF, W64, AIFF or CAF file")
+	}
+
+	headerSize := int64(8)
+	switch c.kind {
+	case w64Container:
+		headerSize = 24
+	case cafContainer:
+		headerSize = 12
+	}
+	ds64 := int64(-1)
+	for pos+headerSize <= c.length {
+		if _, err = r.Seek(pos, os.SEEK_SET); err != nil {
+			return nil, err
+		}
+		h := make([]byte, headerSize)
+		if _, err = io.ReadFull(r, h); err != nil {
+			return nil, err
+		}
+		if !printableID(h[:4]) {
+			return c, nil
+		}
+		ch := chunk{id: string(h[:4]), pos: pos, data: pos + headerSize}
+		switch c.kind {
+		case w64Container:
+			ch.size = int64(c.order.Uint64(h[16:])) - headerSize
+		case cafContainer:
+			ch.size = int64(c.order.Uint64(h[4:]))
+			if ch.size == -1 && ch.id == "data" {
+				ch.size = c.length - ch.data
+			}
+		default:
+			ch.size = int64(c.order.Uint32(h[4:]))
+		}
+		if c.kind == rf64Container {
+			if ch.id == "ds64" && ch.size >= 16 {
+				var sizes [16]byte
+				if _, err = io.ReadFull(r, sizes[:]); err != nil {
+					return nil, err
+				}
+				ds64 = int64(c.order.Uint64(sizes[8:]))
+			}
+			if ch.id == "data" && ch.size == 0xFFFFFFFF && ds64 >= 0 {
+				ch.size = ds64
+			}
+		}
+		if ch.size < 0 {
+			return c, nil
+		}
+		next := ch.data + ch.size
+		switch c.kind {
+		case w64Container:
+			if next = (next + 7) &^ 7; next > c.length && ch.data+ch.size <= c.length {
+				next = c.length
+			}
+		case riffContainer, rf64Container, aiffContainer:
+			// a missing pad byte after the last chunk is common enough to let through
+			if next&1 != 0 && next+1 <= c.length {
+				next++
+			}
+		}
+		c.chunks = append(c.chunks, ch)
+		if next > c.length {
+			return c, nil
+		}
+		pos = next
+	}
+	c.clean = pos == c.length
+	return c, nil
+}
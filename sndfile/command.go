@@ -27,16 +27,15 @@ func GetLibVersion() (s string, err error) {
 	return
 }
 
-// Retrieve the log buffer generated when opening a file as a string. This log buffer can often contain a good reason for why libsndfile failed to open a particular file.
+// Retrieve the log buffer generated when opening a file as a string. This log buffer can often contain a good reason for why libsndfile failed to open a particular file. The File returned alongside an error by Open can still be asked for its log.
 func (f *File) GetLogInfo() (s string, err error) {
-	l := C.sf_command(f.s, C.SFC_GET_LOG_INFO, nil, 0)
-	c := make([]byte, l)
-	m := C.sf_command(f.s, C.SFC_GET_LOG_INFO, unsafe.Pointer(&c[0]), l)
-
-	if m != l {
-		c = c[0:m]
+	// libsndfile won't report the length of the log, but keeps it in a fixed size buffer
+	c := make([]byte, 2048+1)
+	m := C.sf_command(f.s, C.SFC_GET_LOG_INFO, unsafe.Pointer(&c[0]), C.int(len(c)))
+	if m < 0 || int(m) > len(c) {
+		return "", errors.New("couldn't get log info")
 	}
-	s = string(c)
+	s = string(c[:m])
 	return
 }
 
@@ -223,8 +222,15 @@ func (f *File) SetAddPeakChunk(set bool) bool {
 //The header of an audio file is normally written by libsndfile when the file is closed using sf_close().
 
 //There are however situations where large files are being generated and it would be nice to have valid data in the header before the file is complete. Using this command will update the file header to reflect the amount of data written to the file so far. Other programs opening the file for read (before any more data is written) will then read a valid sound file header.
-func (f *File) UpdateHeaderNow() {
+
+// libsndfile doesn't pass on the result of writing the header, and its errors stick, so an error recorded by the update itself is returned.
+func (f *File) UpdateHeaderNow() error {
+	before := C.sf_error(f.s)
 	C.sf_command(f.s, C.SFC_UPDATE_HEADER_NOW, nil, 0)
+	if e := C.sf_error(f.s); e != 0 && e != before {
+		return sErrorType(e)
+	}
+	return nil
 }
 
 //Similar to SFC_UPDATE_HEADER_NOW but updates the header at the end of every call to the sf_write* functions.
@@ -232,6 +238,13 @@ func (f *File) SetUpdateHeaderAuto(set bool) bool {
 	return f.genericBoolBoolCmd(C.SFC_SET_UPDATE_HEADER_AUTO, set)
 }
 
+//When writing an RF64 file, have libsndfile write a plain WAV header on close instead if the file turns out to be smaller than 4 GiB. Returns true if downgrading is now on.
+
+//Note : This call must be made before any data is written to the file.
+func (f *File) SetRF64AutoDowngrade(set bool) bool {
+	return f.genericBoolBoolCmd(C.SFC_RF64_AUTO_DOWNGRADE, set)
+}
+
 // Truncates a file to /count/ frames.  After this command, both the read and the write pointer will be at the new end of the file. This command will fail (returning non-zero) if the requested truncate position is beyond the end of the file.
 func (f *File) Truncate(count int64) (err error) {
 	r := C.sf_command(f.s, C.SFC_FILE_TRUNCATE, unsafe.Pointer(&count), 8)
@@ -360,7 +373,7 @@ func broadcastFromC(c *C.SF_BROADCAST_INFO) *BroadcastInfo {
 	bi.Time_reference_high = uint32(c.time_reference_high)
 	bi.Version = uint16(c.version)
 	bi.Umid = trim(C.GoStringN(&c.umid[0], C.int(len(c.umid[:]))))
-	bi.Coding_history = make([]int8, c.coding_history_size, 256)
+	bi.Coding_history = make([]int8, 0, c.coding_history_size)
 	for i, r := range c.coding_history {
 		if i >= int(c.coding_history_size) {
 			break
@@ -382,6 +395,50 @@ func (f *File) GetBroadcastInfo() (bi *BroadcastInfo, ok bool) {
 	return
 }
 
+// The most coding history bytes a BroadcastInfo can carry; any more are dropped by SetBroadcastInfo.
+const codingHistorySize = len(C.SF_BROADCAST_INFO{}.coding_history)
+
+func broadcastToC(bi *BroadcastInfo) *C.SF_BROADCAST_INFO {
+	c := new(C.SF_BROADCAST_INFO)
+	arrFromGoString(c.description[:], bi.Description)
+	arrFromGoString(c.originator[:], bi.Originator)
+	arrFromGoString(c.originator_reference[:], bi.Originator_reference)
+	arrFromGoString(c.origination_date[:], bi.Origination_date)
+	arrFromGoString(c.origination_time[:], bi.Origination_time)
+	c.time_reference_low = C.uint32_t(bi.Time_reference_low)
+	c.time_reference_high = C.uint32_t(bi.Time_reference_high)
+	c.version = C.short(bi.Version)
+	arrFromGoString(c.umid[:], bi.Umid)
+	n := 0
+	for i, r := range bi.Coding_history {
+		if i >= len(c.coding_history) {
+			break
+		}
+		c.coding_history[i] = C.char(r)
+		n++
+	}
+	c.coding_history_size = C.uint32_t(n)
+	return c
+}
+
+// Write the Broadcast Extension Chunk to a WAV (and related) file. This must be done before any audio data is written.
+func (f *File) SetBroadcastInfo(bi *BroadcastInfo) (err error) {
+	c := broadcastToC(bi)
+	r := C.sf_command(f.s, C.SFC_SET_BROADCAST_INFO, unsafe.Pointer(c), C.int(unsafe.Sizeof(*c)))
+	if r != C.SF_TRUE {
+		err = f.cmdError("SetBroadcastInfo")
+	}
+	return
+}
+
+// cmdError builds the error for an sf_command that reported failure. Several commands fail without libsndfile recording why, usually because the file format doesn't support them.
+func (f *File) cmdError(cmd string) error {
+	if e := C.sf_error(f.s); e != 0 {
+		return sErrorType(e)
+	}
+	return errors.New(cmd + ": not supported for this file")
+}
+
 func arrFromGoString(arr []C.char, src string) {
 	for i, r := range src {
 		if i >= len(arr) {
@@ -450,9 +507,9 @@ type Instrument struct {
 // Return pointer to populated structure if the file header contains instrument information for the file. nil otherwise.
 func (f *File) GetInstrument() (i *Instrument) {
 	c := new(C.SF_INSTRUMENT)
-	i = new(Instrument)
 	r := C.sf_command(f.s, C.SFC_GET_INSTRUMENT, unsafe.Pointer(c), C.int(unsafe.Sizeof(*c)))
 	if r == C.SF_TRUE {
+		i = new(Instrument)
 		i.Gain = int(c.gain)
 		i.Basenote = int8(c.basenote)
 		i.Detune = int8(c.detune)
@@ -471,6 +528,100 @@ func (f *File) GetInstrument() (i *Instrument) {
 	return
 }
 
+// Set the instrument information to be written to the file header. This must be done before any audio data is written.
+func (f *File) SetInstrument(i *Instrument) (err error) {
+	c := new(C.SF_INSTRUMENT)
+	c.gain = C.int(i.Gain)
+	c.basenote = C.char(i.Basenote)
+	c.detune = C.char(i.Detune)
+	c.velocity_lo = C.char(i.Velocity[0])
+	c.velocity_hi = C.char(i.Velocity[1])
+	c.key_lo = C.char(i.Key[0])
+	c.key_hi = C.char(i.Key[1])
+	c.loop_count = C.int(i.LoopCount)
+	for index, loop := range i.Loops {
+		c.loops[index].mode = C.int(loop.Mode)
+		c.loops[index].start = C.uint32_t(loop.Start)
+		c.loops[index].end = C.uint32_t(loop.End)
+		c.loops[index].count = C.uint32_t(loop.Count)
+	}
+	r := C.sf_command(f.s, C.SFC_SET_INSTRUMENT, unsafe.Pointer(c), C.int(unsafe.Sizeof(*c)))
+	if r != C.SF_TRUE {
+		err = f.cmdError("SetInstrument")
+	}
+	return
+}
+
+type CuePoint struct {
+	Index        int32
+	Position     uint32
+	FccChunk     int32
+	ChunkStart   int32
+	BlockStart   int32
+	SampleOffset uint32
+	Name         string
+}
+
+// Retrieve the cue markers stored in the file header (WAV "cue " chunk, AIFF MARK chunk).
+
+// Returns the cue points and true if the file contains any, otherwise nil and false.
+func (f *File) GetCues() (cues []CuePoint, ok bool) {
+	var count C.uint32_t
+	r := C.sf_command(f.s, C.SFC_GET_CUE_COUNT, unsafe.Pointer(&count), C.int(unsafe.Sizeof(count)))
+	if r != C.SF_TRUE || count == 0 {
+		return
+	}
+	c := new(C.SF_CUES)
+	r = C.sf_command(f.s, C.SFC_GET_CUE, unsafe.Pointer(c), C.int(unsafe.Sizeof(*c)))
+	if r != C.SF_TRUE {
+		return
+	}
+	n := int(c.cue_count)
+	if n > len(c.cue_points) {
+		n = len(c.cue_points)
+	}
+	if n == 0 {
+		return
+	}
+	cues = make([]CuePoint, n)
+	for i := range cues {
+		p := &c.cue_points[i]
+		cues[i].Index = int32(p.indx)
+		cues[i].Position = uint32(p.position)
+		cues[i].FccChunk = int32(p.fcc_chunk)
+		cues[i].ChunkStart = int32(p.chunk_start)
+		cues[i].BlockStart = int32(p.block_start)
+		cues[i].SampleOffset = uint32(p.sample_offset)
+		cues[i].Name = trim(C.GoStringN(&p.name[0], C.int(len(p.name))))
+	}
+	ok = true
+	return
+}
+
+// Set the cue markers to be written to the file header. At most 100 cue points are supported and this must be done before any audio data is written.
+func (f *File) SetCues(cues []CuePoint) (err error) {
+	c := new(C.SF_CUES)
+	if len(cues) > len(c.cue_points) {
+		return errors.New("SetCues: too many cue points")
+	}
+	c.cue_count = C.uint32_t(len(cues))
+	for i, cue := range cues {
+		p := &c.cue_points[i]
+		p.indx = C.int32_t(cue.Index)
+		p.position = C.uint32_t(cue.Position)
+		p.fcc_chunk = C.int32_t(cue.FccChunk)
+		p.chunk_start = C.int32_t(cue.ChunkStart)
+		p.block_start = C.int32_t(cue.BlockStart)
+		p.sample_offset = C.uint32_t(cue.SampleOffset)
+		arrFromGoString(p.name[:len(p.name)-1], cue.Name)
+	}
+	r := C.sf_command(f.s, C.SFC_SET_CUE, unsafe.Pointer(c), C.int(unsafe.Sizeof(*c)))
+	if r != C.SF_TRUE {
+		err = f.cmdError("SetCues")
+	}
+	return
+}
+
 // This allows libsndfile experts to use the command interface for commands not currently supported. See http://www.mega-nerd.com/libsndfile/command.html
 // The f argument may be nil in cases where the command does not require a SNDFILE argument.
 // The method's cmd, data, and datasize arguments are used the same way as the correspondingly named arguments for sf_command
@@ -506,11 +657,11 @@ const (
 	ChannelMapTopRearLeft        = C.SF_CHANNEL_MAP_TOP_REAR_LEFT         /* Apple and MS call this 'Top Back Left' */
 	ChannelMapTopRearRight       = C.SF_CHANNEL_MAP_TOP_REAR_RIGHT        /* Apple and MS call this 'Top Back Right' */
 	ChannelMapTopRearCenter      = C.SF_CHANNEL_MAP_TOP_REAR_CENTER       /* Apple and MS call this 'Top Back Center' */
-	//ChannelMapAmbisonicBW = C.SF_CHANNEL_MAP_AMBISONIC_B_W
-	//ChannelMapAmbisonicBX = C.SF_CHANNEL_MAP_AMBISONIC_B_X
-	//ChannelMapAmbisonicBY = C.SF_CHANNEL_MAP_AMBISONIC_B_Y
-	//ChannelMapAmbisonicBZ = C.SF_CHANNEL_MAP_AMBISONIC_B_Z
-	//ChannelMapMax = C.SF_CHANNEL_MAP_MAX
+	ChannelMapAmbisonicBW        = C.SF_CHANNEL_MAP_AMBISONIC_B_W
+	ChannelMapAmbisonicBX        = C.SF_CHANNEL_MAP_AMBISONIC_B_X
+	ChannelMapAmbisonicBY        = C.SF_CHANNEL_MAP_AMBISONIC_B_Y
+	ChannelMapAmbisonicBZ        = C.SF_CHANNEL_MAP_AMBISONIC_B_Z
+	ChannelMapMax                = C.SF_CHANNEL_MAP_MAX
 )
 
 // Returns a slice full of integers detailing the position of each channel in the file. err will be non-nil on an actual error
@@ -525,7 +676,7 @@ func (f *File) GetChannelMapInfo() (channels []int32, err error) {
 
 func (f *File) SetChannelMapInfo(channels []int32) (err error) {
 	if int32(len(channels)) != f.Format.Channels {
-		err = errors.New("channel map passed in didn't match file channel count " + string(len(channels)) + " != " + string(f.Format.Channels))
+		return fmt.Errorf("channel map passed in didn't match file channel count %d != %d", len(channels), f.Format.Channels)
 	}
 	r := GenericCmd(f, C.SFC_SET_CHANNEL_MAP_INFO, unsafe.Pointer(&channels[0]), len(channels)*4)
 	if r == C.SF_FALSE {
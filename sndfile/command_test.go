@@ -528,7 +528,9 @@ func TestUpdateHeader(t *testing.T) {
 		}
 		totsize += len(out) * 2
 	}
-	f.UpdateHeaderNow()
+	if err := f.UpdateHeaderNow(); err != nil {
+		t.Error("UpdateHeaderNow failed", err)
+	}
 	nl := checkLength(t)
 	if int(nl) != totsize {
 		t.Error("bad size?", nl, "!=", totsize)
@@ -0,0 +1,237 @@
+package sndfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Concat joins the files at srcs end to end into a new file at dst with the format given by dstInfo. It is ConcatCrossfade with no crossfade.
+func Concat(dst string, dstInfo Info, srcs ...string) error {
+	return ConcatCrossfade(dst, dstInfo, 0, srcs...)
+}
+
+// ConcatCrossfade joins the files at srcs end to end into a new file at dst with the format given by dstInfo, overlapping each pair by crossfade with an equal power fade. Zero Samplerate and Channels fields in dstInfo are taken from the first source. Each source is converted to the destination's rate, channel count and layout as Convert would; the layout is the first source's if it has the destination's channel count, and the DefaultChannelMap otherwise. Where the format can hold them, the strings of the first source are copied and a cue point is set where each source after the first begins. A crossfade is shortened where a source is too short for it.
+//
+// If joining fails, the partly written dst is removed.
+func ConcatCrossfade(dst string, dstInfo Info, crossfade time.Duration, srcs ...string) (err error) {
+	if len(srcs) == 0 {
+		return errors.New("Concat: no sources")
+	}
+	ins := make([]*File, len(srcs))
+	defer func() {
+		for _, in := range ins {
+			if in != nil {
+				in.Close()
+			}
+		}
+	}()
+	for k, src := range srcs {
+		var si Info
+		if ins[k], err = Open(src, Read, &si); err != nil {
+			return err
+		}
+	}
+	if dstInfo.Samplerate == 0 {
+		dstInfo.Samplerate = ins[0].Format.Samplerate
+	}
+	if dstInfo.Channels == 0 {
+		dstInfo.Channels = ins[0].Format.Channels
+	}
+	channels := int(dstInfo.Channels)
+	to := DefaultChannelMap(channels)
+	if ins[0].Format.Channels == dstInfo.Channels {
+		to = fileChannelMap(ins[0])
+	}
+
+	// cues can only be set before any audio is written, so the joins are placed from the length of each source up front; a source that doesn't know its length is read through first to count its frames
+	reads := make([]func([]float64) (int64, error), len(ins))
+	lengths := make([]int64, len(ins))
+	for k, in := range ins {
+		if in.Format.Frames < 0 {
+			if in.Format.Frames, err = countFrames(in); err != nil {
+				return fmt.Errorf("Concat: %s: %v", srcs[k], err)
+			}
+		}
+		if reads[k], lengths[k], err = convertedReader(in, nil, to, channels, dstInfo.Samplerate, ResampleMedium); err != nil {
+			return fmt.Errorf("Concat: %s: %v", srcs[k], err)
+		}
+	}
+	fades, starts := concatJoins(lengths, durationFrames(crossfade, dstInfo.Samplerate))
+
+	out, err := Open(dst, Write, &dstInfo)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(dst)
+		}
+	}()
+	support := MetadataSupport(dstInfo)
+	if _, err = CopyMetadata(out, ins[0], CopyStrings); err != nil {
+		return err
+	}
+	if to != nil && support&CopyChannelMap != 0 {
+		if err = out.SetChannelMapInfo(to); err != nil {
+			return err
+		}
+	}
+	if len(starts) > 1 && support&CopyCues != 0 {
+		cues := make([]CuePoint, len(starts)-1)
+		for k := range cues {
+			p := uint32(starts[k+1])
+			cues[k] = CuePoint{Index: int32(k + 1), Position: p, FccChunk: 0x61746164, SampleOffset: p}
+		}
+		if err = out.SetCues(cues); err != nil {
+			return err
+		}
+	}
+	out.SetClipping(true)
+
+	write := func(b []float64) error {
+		if len(b) == 0 {
+			return nil
+		}
+		n, err := out.WriteFrames(b)
+		if err == nil && n != int64(len(b)/channels) {
+			err = errors.New("Concat: short write")
+		}
+		return err
+	}
+	hold := int(durationFrames(crossfade, dstInfo.Samplerate)) * channels
+	var pending []float64 // the end of the last source, held back for the next crossfade
+	buf := make([]float64, 4096*channels)
+	for k, read := range reads {
+		n := int(fades[k]) * channels
+		if n > len(pending) {
+			n = len(pending)
+		}
+		if n > 0 {
+			head := make([]float64, n)
+			got, err := readFull(read, head, channels)
+			if err != nil {
+				return err
+			}
+			head = head[:int(got)*channels]
+			tail := pending[len(pending)-len(head):]
+			if err = write(pending[:len(pending)-len(head)]); err != nil {
+				return err
+			}
+			frames := len(head) / channels
+			for i := range head {
+				t := (float64(i/channels) + 0.5) / float64(frames)
+				head[i] = tail[i]*FadeEqualPower.Gain(1-t) + head[i]*FadeEqualPower.Gain(t)
+			}
+			if err = write(head); err != nil {
+				return err
+			}
+			pending = pending[:0]
+		}
+		for {
+			n, err := read(buf)
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				break
+			}
+			pending = append(pending, buf[:int(n)*channels]...)
+			if len(pending) > hold {
+				if err = write(pending[:len(pending)-hold]); err != nil {
+					return err
+				}
+				pending = append(pending[:0], pending[len(pending)-hold:]...)
+			}
+		}
+	}
+	return write(pending)
+}
+
+// concatJoins returns, for sources of the given lengths joined with crossfades of up to crossfade frames, the length of the fade into each source and the frame of the output at which each begins. A fade is no longer than the source it fades into, nor than what is left of the one before after its own fade in.
+func concatJoins(lengths []int64, crossfade int64) (fades, starts []int64) {
+	fades = make([]int64, len(lengths))
+	starts = make([]int64, len(lengths))
+	var end int64
+	for k, l := range lengths {
+		if k > 0 {
+			fades[k] = min64(crossfade, min64(lengths[k-1]-fades[k-1], l))
+		}
+		starts[k] = end - fades[k]
+		end = starts[k] + l
+	}
+	return fades, starts
+}
+
+// countFrames reads src, which must be open for reading, through from the start and returns the number of frames it gave, leaving the read position at the start.
+func countFrames(src *File) (frames int64, err error) {
+	if _, err = src.Seek(0, Set); err != nil {
+		return 0, err
+	}
+	buf := make([]int32, 4096*int(src.Format.Channels))
+	for {
+		n, err := src.ReadFrames(buf)
+		if err != nil {
+			return 0, err
+		}
+		if n == 0 {
+			break
+		}
+		frames += n
+	}
+	_, err = src.Seek(0, Set)
+	return frames, err
+}
+
+// convertedReader returns a function reading the audio of src as float64, remixed through m if it is not nil, or else to the layout to, or only checked to have the right number of channels if to is nil too, and resampled to rate, with the number of frames it gives.
+func convertedReader(src *File, m RemixMatrix, to []int32, channels int, rate int32, quality ResampleQuality) (read func([]float64) (int64, error), frames int64, err error) {
+	src.SetDoubleNormalization(true)
+	read = func(b []float64) (int64, error) { return src.ReadFrames(b) }
+	format := src.Format
+	if m != nil {
+		rm, err := newRemixerMatrix(read, format, m)
+		if err != nil {
+			return nil, 0, err
+		}
+		if int(rm.Format.Channels) != channels {
+			return nil, 0, fmt.Errorf("matrix gives %d channels, expected %d", rm.Format.Channels, channels)
+		}
+		read, format = rm.ReadFrames, rm.Format
+	} else if to == nil {
+		if int(format.Channels) != channels {
+			return nil, 0, fmt.Errorf("no layout to convert %d channels to %d", format.Channels, channels)
+		}
+	} else if !equalChannelMaps(to, fileChannelMap(src)) {
+		rm, err := newRemixer(read, format, fileChannelMap(src), to)
+		if err != nil {
+			return nil, 0, err
+		}
+		read, format = rm.ReadFrames, rm.Format
+	}
+	if format.Samplerate != rate {
+		rs, err := newResampler(read, format, int(rate), quality)
+		if err != nil {
+			return nil, 0, err
+		}
+		read, format = rs.ReadFrames, rs.Format
+	}
+	return read, format.Frames, nil
+}
+
+// readFull reads frames of the given number of channels from read until b is full or the audio ends, and returns the number of frames read.
+func readFull(read func([]float64) (int64, error), b []float64, channels int) (int64, error) {
+	var done int64
+	for len(b) > 0 {
+		n, err := read(b)
+		if err != nil || n == 0 {
+			return done, err
+		}
+		done += n
+		b = b[int(n)*channels:]
+	}
+	return done, nil
+}
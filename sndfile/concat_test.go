@@ -0,0 +1,98 @@
+package sndfile
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestConcat(t *testing.T) {
+	a := tone(48000, 2, -20, 0, 1)
+	c := tone(12000, 2, -6, 0)
+	// as the float files hold them
+	for j := range a {
+		a[j] = float64(float32(a[j]))
+	}
+	for j := range c {
+		c[j] = float64(float32(c[j]))
+	}
+	writeFloats(t, "concata.wav", Info{Samplerate: 48000, Channels: 2, Format: SF_FORMAT_WAV | SF_FORMAT_FLOAT}, a, nil)
+	writeFloats(t, "concatb.wav", Info{Samplerate: 24000, Channels: 1, Format: SF_FORMAT_WAV | SF_FORMAT_FLOAT}, tone(12000, 1, -20, 0), nil)
+	writeFloats(t, "concatc.wav", Info{Samplerate: 48000, Channels: 2, Format: SF_FORMAT_WAV | SF_FORMAT_FLOAT}, c, nil)
+
+	read := func() ([]float64, []CuePoint) {
+		var i Info
+		f, err := Open("concatdst.wav", Read, &i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if i.Samplerate != 48000 || i.Channels != 2 {
+			t.Fatalf("joined as %+v, expected the first source's rate and channels", i)
+		}
+		b := make([]float64, 2*i.Frames)
+		f.ReadFrames(b)
+		cues, _ := f.GetCues()
+		return b, cues
+	}
+
+	// the mono source at half the rate is converted to stereo at twice its length
+	if err := Concat("concatdst.wav", Info{Format: SF_FORMAT_WAV | SF_FORMAT_FLOAT}, "concata.wav", "concatb.wav", "concatc.wav"); err != nil {
+		t.Fatal(err)
+	}
+	b, cues := read()
+	if len(b) != 2*84000 {
+		t.Fatalf("joined %d frames, expected %d", len(b)/2, 84000)
+	}
+	if len(cues) != 2 || cues[0].Position != 48000 || cues[1].Position != 72000 {
+		t.Errorf("cues %+v, expected them at 48000 and 72000", cues)
+	}
+	for j := range a {
+		if b[j] != a[j] {
+			t.Fatalf("sample %d of the first source is %g, expected %g", j, b[j], a[j])
+		}
+	}
+	for j := range c {
+		if b[2*72000+j] != c[j] {
+			t.Fatalf("sample %d of the last source is %g, expected %g", j, b[2*72000+j], c[j])
+		}
+	}
+	if b[2*60000] == 0 || b[2*60000] != b[2*60000+1] {
+		t.Errorf("mono source converted to %g, %g", b[2*60000], b[2*60000+1])
+	}
+
+	// with a 100 ms crossfade the sources overlap by 4800 frames
+	if err := ConcatCrossfade("concatdst.wav", Info{Format: SF_FORMAT_WAV | SF_FORMAT_FLOAT}, 100*time.Millisecond, "concata.wav", "concatc.wav"); err != nil {
+		t.Fatal(err)
+	}
+	b, cues = read()
+	if len(b) != 2*55200 || len(cues) != 1 || cues[0].Position != 43200 {
+		t.Fatalf("joined %d frames with cues %+v, expected %d with one at 43200", len(b)/2, cues, 55200)
+	}
+	for _, n := range []int{0, 2400, 4799} {
+		x := (float64(n) + 0.5) / 4800
+		want := a[2*(43200+n)]*math.Cos(x*math.Pi/2) + c[2*n]*math.Sin(x*math.Pi/2)
+		if got := b[2*(43200+n)]; math.Abs(got-want) > 1e-6 {
+			t.Errorf("frame %d of the crossfade is %g, expected %g", n, got, want)
+		}
+	}
+	for j := 2 * 4800; j < len(c); j++ {
+		if b[2*43200+j] != c[j] {
+			t.Fatalf("sample %d of the second source is %g, expected %g", j, b[2*43200+j], c[j])
+		}
+	}
+	// a source of unknown length is counted by reading it through
+	var i Info
+	f, err := Open("concata.wav", Read, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.Seek(100, Set)
+	if n, err := countFrames(f); err != nil || n != 48000 {
+		t.Errorf("counted %d frames, expected 48000: %v", n, err)
+	}
+	if pos, _ := f.Seek(0, Current); pos != 0 {
+		t.Errorf("counting left the read position at %d", pos)
+	}
+}
@@ -0,0 +1,200 @@
+package sndfile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ConvertOptions controls how Convert and ConvertFile move audio from one file to another.
+type ConvertOptions struct {
+	BlockFrames int                     // frames per read/write, 4096 if zero
+	Clip        bool                    // clip instead of wrapping when float data exceeds the range of an integer destination
+	Normalize   bool                    // scale the audio so its peak reaches full scale; costs an extra pass over the source
+	Metadata    CopyPolicy              // metadata to carry over to the destination
+	Resample    ResampleQuality         // filter used when the sample rates differ
+	ChannelMap  []int32                 // layout of the destination, DefaultChannelMap for its channel count if nil
+	Matrix      RemixMatrix             // if not nil, remix through this matrix instead of converting between layouts
+	Progress    func(done, total int64) // called after every block with the number of frames written so far
+}
+
+// DefaultConvertOptions are used when nil options are passed to Convert or ConvertFile.
+var DefaultConvertOptions = ConvertOptions{Metadata: CopyAll, Resample: ResampleMedium}
+
+// Convert converts the file at src into a new file at dst with the format given by dstInfo. Zero Samplerate and Channels fields in dstInfo are taken from the source.
+
+// If the conversion fails or ctx is cancelled, the partly written dst is removed.
+func Convert(ctx context.Context, src, dst string, dstInfo Info, opts *ConvertOptions) (err error) {
+	var si Info
+	in, err := Open(src, Read, &si)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if dstInfo.Samplerate == 0 {
+		dstInfo.Samplerate = si.Samplerate
+	}
+	if dstInfo.Channels == 0 {
+		dstInfo.Channels = si.Channels
+	}
+	out, err := Open(dst, Write, &dstInfo)
+	if err != nil {
+		return err
+	}
+	err = ConvertFile(ctx, out, in, opts)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst)
+	}
+	return err
+}
+
+// ConvertFile streams all audio from the current position of src to dst, which must be open for writing with no audio written yet. Metadata is copied first, as selected by the options. dst is not closed, and is left as it is if the conversion fails.
+
+// Audio passes through int32 when both files hold integer PCM at the same rate and in the same layout, so that no precision is lost to float scaling, and through float64 otherwise. If the channel layouts differ the audio is remixed as a Remixer does, and the new layout is set as the destination's channel map where its format can hold one. If the sample rates differ the audio is then passed through a Resampler. Downmixing and resampling can both take the audio past the source's peak, so set Clip when converting loud material to an integer format.
+func ConvertFile(ctx context.Context, dst, src *File, opts *ConvertOptions) (err error) {
+	if opts == nil {
+		opts = &DefaultConvertOptions
+	}
+	if opts.Metadata != 0 {
+		if _, err = CopyMetadata(dst, src, opts.Metadata); err != nil {
+			return err
+		}
+	}
+	dst.SetClipping(opts.Clip)
+
+	gain := 1.0
+	if opts.Normalize {
+		peak, err := src.CalcNormSignalMax()
+		if err != nil {
+			return err
+		}
+		if peak > 0 {
+			gain = 1 / peak
+		}
+	}
+
+	block := opts.BlockFrames
+	if block <= 0 {
+		block = 4096
+	}
+	channels := int(dst.Format.Channels)
+	total := src.Format.Frames
+	var done int64
+
+	// audio that needs remixing or resampling goes through a chain of float64 stages, remixing first so that there are fewer channels to resample when mixing down
+	stage := func(b []float64) (int64, error) { return src.ReadFrames(b) }
+	format := src.Format
+	direct := true
+	if opts.Matrix != nil || dst.Format.Channels != src.Format.Channels || (opts.ChannelMap != nil && !equalChannelMaps(opts.ChannelMap, fileChannelMap(src))) {
+		var rm *Remixer
+		if opts.Matrix != nil {
+			rm, err = newRemixerMatrix(stage, format, opts.Matrix)
+		} else {
+			to := opts.ChannelMap
+			if to == nil {
+				to = DefaultChannelMap(channels)
+			}
+			rm, err = newRemixer(stage, format, fileChannelMap(src), to)
+		}
+		if err != nil {
+			return err
+		}
+		if rm.Format.Channels != dst.Format.Channels {
+			return fmt.Errorf("Convert: remixing gives %d channels, destination has %d", rm.Format.Channels, dst.Format.Channels)
+		}
+		if cm := rm.ChannelMap(); cm != nil && MetadataSupport(dst.Format)&CopyChannelMap != 0 {
+			if err = dst.SetChannelMapInfo(cm); err != nil {
+				return err
+			}
+		}
+		stage, format, direct = rm.ReadFrames, rm.Format, false
+	}
+	if dst.Format.Samplerate != src.Format.Samplerate {
+		rs, err := newResampler(stage, format, int(dst.Format.Samplerate), opts.Resample)
+		if err != nil {
+			return err
+		}
+		total = rs.Format.Frames
+		stage, direct = rs.ReadFrames, false
+	}
+
+	var buf interface{}
+	var fbuf []float64
+	if gain == 1 && direct && isIntegerFormat(src.Format.Format) && isIntegerFormat(dst.Format.Format) {
+		buf = make([]int32, block*channels)
+	} else {
+		fbuf = make([]float64, block*channels)
+		buf = fbuf
+	}
+	read := func() (int64, error) {
+		if direct {
+			return src.ReadFrames(buf)
+		}
+		return stage(fbuf)
+	}
+
+	for {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		n, err := read()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			break
+		}
+		if gain != 1 {
+			for i := range fbuf[:int(n)*channels] {
+				fbuf[i] *= gain
+			}
+		}
+		w, err := dst.WriteFrames(sliceFrames(buf, int(n)*channels))
+		if err != nil {
+			return err
+		}
+		if w != n {
+			return errors.New("Convert: short write")
+		}
+		done += n
+		if opts.Progress != nil {
+			opts.Progress(done, total)
+		}
+	}
+	return nil
+}
+
+func equalChannelMaps(a, b []int32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if canonicalPosition(a[i]) != canonicalPosition(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isIntegerFormat reports whether the file's samples are integers, so that reading and writing them as int32 is lossless.
+func isIntegerFormat(f Format) bool {
+	switch f & SF_FORMAT_SUBMASK {
+	case SF_FORMAT_FLOAT, SF_FORMAT_DOUBLE, SF_FORMAT_VORBIS:
+		return false
+	}
+	return true
+}
+
+func sliceFrames(buf interface{}, items int) interface{} {
+	switch b := buf.(type) {
+	case []int32:
+		return b[:items]
+	case []float64:
+		return b[:items]
+	}
+	panic("sliceFrames: unexpected buffer type")
+}
@@ -0,0 +1,140 @@
+package sndfile
+
+import (
+	"context"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func writeRamp(t *testing.T, name string, i Info, frames int) []int16 {
+	f, err := Open(name, Write, &i)
+	if err != nil {
+		t.Fatal("couldn't open file to write", err)
+	}
+	f.SetString("ramp", Title)
+	out := make([]int16, frames*int(i.Channels))
+	for n := range out {
+		out[n] = int16(n*7 - 30000)
+	}
+	if _, err = f.WriteItems(out); err != nil {
+		t.Fatal("couldn't write ramp", err)
+	}
+	f.Close()
+	return out
+}
+
+func readAllInt16(t *testing.T, name string) []int16 {
+	var i Info
+	f, err := Open(name, Read, &i)
+	if err != nil {
+		t.Fatal("couldn't open file to read", err)
+	}
+	defer f.Close()
+	in := make([]int16, i.Frames*int64(i.Channels))
+	if _, err = f.ReadItems(in); err != nil {
+		t.Fatal("couldn't read", err)
+	}
+	return in
+}
+
+func TestConvert(t *testing.T) {
+	src := writeRamp(t, "convertsrc.wav", Info{Samplerate: 8000, Channels: 2, Format: SF_FORMAT_WAV | SF_FORMAT_PCM_16}, 10000)
+
+	var calls int
+	var last, total int64
+	opts := DefaultConvertOptions
+	opts.BlockFrames = 1000
+	opts.Progress = func(d, tot int64) {
+		calls++
+		last, total = d, tot
+	}
+	err := Convert(context.Background(), "convertsrc.wav", "convertdst.aiff", Info{Format: SF_FORMAT_AIFF | SF_FORMAT_PCM_24}, &opts)
+	if err != nil {
+		t.Fatal("convert failed", err)
+	}
+	if calls != 10 || last != 10000 || total != 10000 {
+		t.Errorf("unexpected progress: %d calls, last %d of %d", calls, last, total)
+	}
+	if !reflect.DeepEqual(readAllInt16(t, "convertdst.aiff"), src) {
+		t.Error("16 bit samples didn't survive conversion to 24 bit")
+	}
+	m, err := Probe("convertdst.aiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Strings[Title] != "ramp" || m.Info.Channels != 2 || m.Info.Samplerate != 8000 {
+		t.Errorf("format or metadata not carried over %v", m)
+	}
+
+	// through float the samples are scaled but every value is still exact
+	err = Convert(context.Background(), "convertdst.aiff", "convertdst.wav", Info{Format: SF_FORMAT_WAV | SF_FORMAT_FLOAT}, nil)
+	if err != nil {
+		t.Fatal("convert failed", err)
+	}
+	var i Info
+	f, err := Open("convertdst.wav", Read, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	in := make([]float32, len(src))
+	f.ReadItems(in)
+	for n := range src {
+		if in[n] != float32(src[n])/0x8000 {
+			t.Fatalf("sample %d is %v, expected %v", n, in[n], float32(src[n])/0x8000)
+		}
+	}
+}
+
+func TestConvertNormalize(t *testing.T) {
+	var i Info
+	i.Samplerate = 8000
+	i.Channels = 1
+	i.Format = SF_FORMAT_WAV | SF_FORMAT_PCM_16
+	f, err := Open("convertquiet.wav", Write, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteItems([]int16{0, 4096, -8192, 2048})
+	f.Close()
+
+	opts := ConvertOptions{Normalize: true}
+	err = Convert(context.Background(), "convertquiet.wav", "convertloud.wav", Info{Format: SF_FORMAT_WAV | SF_FORMAT_FLOAT}, &opts)
+	if err != nil {
+		t.Fatal("convert failed", err)
+	}
+	f, err = Open("convertloud.wav", Read, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	in := make([]float64, 4)
+	f.ReadItems(in)
+	want := []float64{0, 0.5, -1, 0.25}
+	for n := range want {
+		if math.Abs(in[n]-want[n]) > 1e-6 {
+			t.Errorf("normalised output %v, expected %v", in, want)
+			break
+		}
+	}
+}
+
+func TestConvertCancel(t *testing.T) {
+	writeRamp(t, "convertsrc.wav", Info{Samplerate: 8000, Channels: 1, Format: SF_FORMAT_WAV | SF_FORMAT_PCM_16}, 10000)
+	ctx, cancel := context.WithCancel(context.Background())
+	opts := ConvertOptions{BlockFrames: 100}
+	opts.Progress = func(d, tot int64) {
+		if d >= 500 {
+			cancel()
+		}
+	}
+	err := Convert(ctx, "convertsrc.wav", "convertcancel.wav", Info{Format: SF_FORMAT_WAV | SF_FORMAT_PCM_24}, &opts)
+	if err != context.Canceled {
+		t.Errorf("expected cancellation, got %v", err)
+	}
+	if _, err = os.Stat("convertcancel.wav"); !os.IsNotExist(err) {
+		t.Error("partial output was left behind", err)
+	}
+}
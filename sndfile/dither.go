@@ -0,0 +1,190 @@
+package sndfile
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+)
+
+// Dither selects the noise added to float samples when they are written to a file holding integers, so that the rounding error becomes a steady noise floor instead of distortion that follows the signal.
+type Dither int
+
+const (
+	DitherNone        Dither = iota // round to the nearest integer, as libsndfile does
+	DitherRectangular               // uniform noise of 1 LSB peak to peak; removes distortion but leaves noise modulation
+	DitherTriangular                // triangular (TPDF) noise of 2 LSB peak to peak, the sum of two uniform values; removes distortion and noise modulation
+	DitherHighPass                  // triangular noise made by differencing successive uniform values, which puts more of it at high frequencies
+	DitherShaped                    // triangular noise with the rounding error fed back through a 3 tap filter, moving it away from the frequencies the ear is most sensitive to at 44.1 and 48 kHz
+)
+
+func (d Dither) String() string {
+	switch d {
+	case DitherNone:
+		return "none"
+	case DitherRectangular:
+		return "rectangular"
+	case DitherTriangular:
+		return "triangular"
+	case DitherHighPass:
+		return "high-pass triangular"
+	case DitherShaped:
+		return "noise shaped"
+	}
+	return fmt.Sprintf("Dither(%d)", int(d))
+}
+
+// Wannamaker's F-weighted 3 tap error feedback filter.
+var shapingFilter = [3]float64{1.623, -0.982, 0.109}
+
+// The largest error rounding with triangular noise leaves, in LSBs.
+const maxShapedError = 1.5
+
+type ditherer struct {
+	kind     Dither
+	rng      *rand.Rand
+	scale    float64 // full scale in LSBs of the file's sample width
+	shift    uint    // from the file's sample width to the top of an int32
+	channels int
+	items    int64        // samples written so far, which tells the channel of the next one
+	prev     []float64    // last uniform value per channel, for DitherHighPass
+	errs     [][3]float64 // last rounding errors per channel, for DitherShaped
+	out      []int32
+}
+
+// ditherBits returns the number of bits in the integer samples of a format, or 0 if it doesn't store linear integer samples.
+func ditherBits(f Format) uint {
+	switch f & SF_FORMAT_SUBMASK {
+	case SF_FORMAT_PCM_S8, SF_FORMAT_PCM_U8, SF_FORMAT_DPCM_8:
+		return 8
+	case SF_FORMAT_DWVW_12:
+		return 12
+	case SF_FORMAT_PCM_16, SF_FORMAT_DPCM_16, SF_FORMAT_DWVW_16:
+		return 16
+	case SF_FORMAT_PCM_24, SF_FORMAT_DWVW_24:
+		return 24
+	case SF_FORMAT_PCM_32:
+		return 32
+	}
+	return 0
+}
+
+// SetDither turns on dithering for float32 and float64 samples passed to WriteItems and WriteFrames on a file open for writing, or turns it off with DitherNone. The noise comes from a generator seeded with seed, so that the same audio written with the same seed gives the same file. Dithered samples are clipped to the range of the file's samples rather than wrapped, whatever SetClipping is set to.
+//
+// Dithering needs samples normalised to [-1.0, 1.0], and writes of a float type whose normalisation has been turned off are rounded by libsndfile as usual. It is an error to turn dithering on for a file whose samples aren't linear integers, such as a float or compressed file.
+func (f *File) SetDither(d Dither, seed int64) error {
+	if d == DitherNone {
+		f.dither = nil
+		return nil
+	}
+	if d < DitherNone || d > DitherShaped {
+		return fmt.Errorf("SetDither: unknown dither %d", int(d))
+	}
+	bits := ditherBits(f.Format.Format)
+	if bits == 0 {
+		return errors.New("SetDither: file doesn't hold integer samples")
+	}
+	c := int(f.Format.Channels)
+	f.dither = &ditherer{
+		kind:     d,
+		rng:      rand.New(rand.NewSource(seed)),
+		scale:    math.Ldexp(1, int(bits)-1),
+		shift:    32 - bits,
+		channels: c,
+		prev:     make([]float64, c),
+		errs:     make([][3]float64, c),
+	}
+	return nil
+}
+
+// OpenDither opens a new file for writing as Open does, with dithering turned on as by SetDither.
+func OpenDither(name string, info *Info, d Dither, seed int64) (*File, error) {
+	f, err := Open(name, Write, info)
+	if err != nil {
+		return f, err
+	}
+	if err = f.SetDither(d, seed); err != nil {
+		f.Close()
+		os.Remove(name)
+		return nil, err
+	}
+	return f, nil
+}
+
+// ditherInts returns the samples in, dithered and scaled to the top of an int32 for writing, or nil if in doesn't need dithering. For WriteFrames, whole frames only are returned. The caller reports what it wrote with wrote, so that each write carries on from the channel the last one stopped at.
+func (f *File) ditherInts(in interface{}, frames bool) []int32 {
+	d := f.dither
+	if d == nil {
+		return nil
+	}
+	var n int
+	var at func(int) float64
+	switch b := in.(type) {
+	case []float32:
+		if !f.GetFloatNormalization() {
+			return nil
+		}
+		n, at = len(b), func(i int) float64 { return float64(b[i]) }
+	case []float64:
+		if !f.GetDoubleNormalization() {
+			return nil
+		}
+		n, at = len(b), func(i int) float64 { return b[i] }
+	default:
+		return nil
+	}
+	if frames {
+		n -= n % d.channels
+	}
+	if n == 0 {
+		return nil
+	}
+	if cap(d.out) < n {
+		d.out = make([]int32, n)
+	}
+	out := d.out[:n]
+	for i := range out {
+		out[i] = int32(d.quantize(at(i), int((d.items+int64(i))%int64(d.channels)))) << d.shift
+	}
+	return out
+}
+
+// wrote records that items samples were written to the file, dithered or not.
+func (d *ditherer) wrote(items int64) {
+	if items > 0 {
+		d.items += items
+	}
+}
+
+// quantize returns s, a sample of channel c normalised to [-1.0, 1.0], as a dithered integer of the file's sample width.
+func (d *ditherer) quantize(s float64, c int) int64 {
+	v := s * d.scale
+	var noise float64
+	switch d.kind {
+	case DitherRectangular:
+		noise = d.rng.Float64() - 0.5
+	case DitherTriangular:
+		noise = d.rng.Float64() + d.rng.Float64() - 1
+	case DitherHighPass:
+		r := d.rng.Float64() - 0.5
+		noise = r - d.prev[c]
+		d.prev[c] = r
+	case DitherShaped:
+		e := &d.errs[c]
+		v -= shapingFilter[0]*e[0] + shapingFilter[1]*e[1] + shapingFilter[2]*e[2]
+		noise = d.rng.Float64() + d.rng.Float64() - 1
+	}
+	q := math.Floor(v + noise + 0.5)
+	if q > d.scale-1 {
+		q = d.scale - 1
+	} else if q < -d.scale {
+		q = -d.scale
+	}
+	if d.kind == DitherShaped {
+		// the error of a clipped sample is bounded by what rounding and noise alone could give, so that overload doesn't build up in the filter
+		e := &d.errs[c]
+		e[2], e[1], e[0] = e[1], e[0], math.Max(-maxShapedError, math.Min(maxShapedError, q-v))
+	}
+	return int64(q)
+}
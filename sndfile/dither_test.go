@@ -0,0 +1,146 @@
+package sndfile
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+// quietSine is a 1 kHz sine at 48 kHz with a peak of a third of a 16 bit LSB, which rounding alone turns into silence.
+func quietSine(frames int) []float32 {
+	out := make([]float32, frames)
+	for n := range out {
+		out[n] = float32(math.Sin(2*math.Pi*1000*float64(n)/48000) / 3 / 32768)
+	}
+	return out
+}
+
+func writeDithered(t *testing.T, name string, d Dither, seed int64, in []float32) []int16 {
+	i := Info{Samplerate: 48000, Channels: 1, Format: SF_FORMAT_WAV | SF_FORMAT_PCM_16}
+	f, err := OpenDither(name, &i, d, seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// in two writes, to check the dither carries on between them
+	half := len(in) / 2
+	f.WriteFrames(in[:half])
+	f.WriteItems(in[half:])
+	f.Close()
+	return readAllInt16(t, name)
+}
+
+func TestDither(t *testing.T) {
+	in := quietSine(48000)
+	if out := writeDithered(t, "dithernone.wav", DitherNone, 0, in); !bytes.Equal(int16Bytes(out), make([]byte, 2*len(out))) {
+		t.Error("expected a sine below half an LSB to round to silence")
+	}
+
+	noisePower := func(out []int16, smooth int) float64 {
+		// the power of the error after a moving average of smooth samples, which keeps its low frequencies
+		var sum, p float64
+		for n := range out {
+			sum += float64(out[n]) - float64(in[n])*32768
+			if n >= smooth {
+				sum -= float64(out[n-smooth]) - float64(in[n-smooth])*32768
+			}
+			p += (sum / float64(smooth)) * (sum / float64(smooth))
+		}
+		return p / float64(len(out))
+	}
+	low := map[Dither]float64{}
+	for _, d := range []Dither{DitherRectangular, DitherTriangular, DitherHighPass, DitherShaped} {
+		out := writeDithered(t, "dither.wav", d, 1, in)
+		// the sine survives as the correlation of the output with it
+		var corr, pow float64
+		for n := range out {
+			corr += float64(out[n]) * float64(in[n]) * 32768
+			pow += float64(in[n]) * 32768 * float64(in[n]) * 32768
+		}
+		if g := corr / pow; g < 0.8 || g > 1.2 {
+			t.Errorf("%v: sine came through with gain %g", d, g)
+		}
+		low[d] = noisePower(out, 16)
+
+		again := writeDithered(t, "dither.wav", d, 1, in)
+		if !bytes.Equal(int16Bytes(out), int16Bytes(again)) {
+			t.Errorf("%v: same seed gave different output", d)
+		}
+		other := writeDithered(t, "dither.wav", d, 2, in)
+		if bytes.Equal(int16Bytes(out), int16Bytes(other)) {
+			t.Errorf("%v: different seeds gave the same output", d)
+		}
+	}
+	if low[DitherHighPass] >= low[DitherTriangular] || low[DitherShaped] >= low[DitherTriangular] {
+		t.Errorf("expected less low frequency noise from high-pass and shaped dither: %v", low)
+	}
+
+	i := Info{Samplerate: 48000, Channels: 1, Format: SF_FORMAT_WAV | SF_FORMAT_FLOAT}
+	if _, err := OpenDither("ditherfloat.wav", &i, DitherTriangular, 0); err == nil {
+		t.Error("expected an error dithering a float file")
+	}
+}
+
+func TestDitherClip(t *testing.T) {
+	in := []float32{1, -1, 1.5, -1.5, 0.5}
+	out := writeDithered(t, "dither.wav", DitherTriangular, 3, append(in, in...))
+	// full scale and beyond should clip, not wrap, whatever the dither adds
+	for n, s := range out[:4] {
+		if (n%2 == 0 && s < 32766) || (n%2 == 1 && s > -32767) {
+			t.Errorf("sample %d: got %d, expected it at full scale", n, s)
+		}
+	}
+
+	// with noise shaping, a long overload must not leave the filter unsettled afterwards
+	in = make([]float32, 2000)
+	for n := range in {
+		in[n] = 1.5
+		if n >= 1000 {
+			in[n] = 0.25
+		}
+	}
+	out = writeDithered(t, "dither.wav", DitherShaped, 3, in)
+	for n, s := range out {
+		if n < 1000 && s < 32766 {
+			t.Fatalf("sample %d: got %d, expected it at full scale", n, s)
+		} else if n >= 1000 && (s < 8192-8 || s > 8192+8) {
+			t.Fatalf("sample %d: got %d after the overload, expected about 8192", n, s)
+		}
+	}
+}
+
+func TestDitherPartialFrames(t *testing.T) {
+	in := quietSine(2 * 4800)
+	write := func(name string, writes ...func(f *File)) []int16 {
+		i := Info{Samplerate: 48000, Channels: 2, Format: SF_FORMAT_WAV | SF_FORMAT_PCM_16}
+		f, err := OpenDither(name, &i, DitherShaped, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, w := range writes {
+			w(f)
+		}
+		f.Close()
+		return readAllInt16(t, name)
+	}
+	whole := write("dither.wav", func(f *File) { f.WriteFrames(in) })
+	// each channel's noise shaping carries on from one write to the next, including writes of items
+	parts := write("dither.wav", func(f *File) { f.WriteItems(in[:6]) }, func(f *File) { f.WriteFrames(in[6:100]) }, func(f *File) { f.WriteItems(in[100:]) })
+	if !bytes.Equal(int16Bytes(whole), int16Bytes(parts)) {
+		t.Error("writing in parts changed the dither")
+	}
+	// libsndfile refuses part of a frame, which mustn't be cut down to whole frames and written instead
+	write("dither.wav", func(f *File) {
+		if n, err := f.WriteItems(in[:3]); err == nil || n != 0 {
+			t.Errorf("writing 3 items to a stereo file wrote %d, err %v", n, err)
+		}
+		f.WriteItems(in[:2])
+	})
+}
+
+func int16Bytes(s []int16) []byte {
+	b := make([]byte, 2*len(s))
+	for i, v := range s {
+		b[2*i], b[2*i+1] = byte(v), byte(v>>8)
+	}
+	return b
+}
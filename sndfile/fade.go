@@ -0,0 +1,229 @@
+package sndfile
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// A BlockProcessor changes blocks of interleaved float64 frames in place, as read by File.ReadFrames, keeping track of its position in the audio from one block to the next.
+type BlockProcessor interface {
+	Process(b []float64)
+}
+
+// FadeShape is the curve a fade follows.
+type FadeShape int
+
+const (
+	FadeLinear     FadeShape = iota // gain rises in a straight line
+	FadeEqualPower                  // a quarter sine, so that a crossfade between unrelated material keeps its power
+	FadeLog                         // gain rises evenly in dB over 60 dB, as the ear hears a linear fade
+	FadeSCurve                      // a half cosine, starting and ending gently
+)
+
+func (s FadeShape) String() string {
+	switch s {
+	case FadeLinear:
+		return "linear"
+	case FadeEqualPower:
+		return "equal power"
+	case FadeLog:
+		return "log"
+	case FadeSCurve:
+		return "s-curve"
+	}
+	return fmt.Sprintf("FadeShape(%d)", int(s))
+}
+
+// Gain returns the gain of a fade in at x, from 0 at its start to 1 at its end. The gain of a fade out at x is Gain(1-x).
+func (s FadeShape) Gain(x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	switch s {
+	case FadeEqualPower:
+		return math.Sin(x * math.Pi / 2)
+	case FadeLog:
+		return math.Pow(10, -60*(1-x)/20)
+	case FadeSCurve:
+		return (1 - math.Cos(x*math.Pi)) / 2
+	}
+	return x
+}
+
+// A Fader fades a stretch of audio in at its start and out at its end.
+type Fader struct {
+	Shape    FadeShape
+	channels int
+	in, out  int64 // fade lengths in frames
+	length   int64 // frames in the audio
+	pos      int64
+}
+
+// NewFader returns a Fader for length frames of audio at samplerate, fading in over fadeIn and out over fadeOut with the given shape. Either fade may be zero.
+func NewFader(shape FadeShape, samplerate, channels int, length int64, fadeIn, fadeOut time.Duration) (*Fader, error) {
+	if channels < 1 {
+		return nil, errors.New("NewFader: no channels")
+	}
+	return &Fader{
+		Shape:    shape,
+		channels: channels,
+		in:       durationFrames(fadeIn, int32(samplerate)),
+		out:      durationFrames(fadeOut, int32(samplerate)),
+		length:   length,
+	}, nil
+}
+
+// Process applies the fades to the next frames of the audio.
+func (f *Fader) Process(b []float64) {
+	for i := 0; i+f.channels <= len(b); i += f.channels {
+		g := 1.0
+		if f.pos < f.in {
+			g = f.Shape.Gain((float64(f.pos) + 0.5) / float64(f.in))
+		}
+		if left := f.length - f.pos; left <= f.out {
+			g *= f.Shape.Gain((float64(left) - 0.5) / float64(f.out))
+		}
+		if g != 1 {
+			for c := i; c < i+f.channels; c++ {
+				b[c] *= g
+			}
+		}
+		f.pos++
+	}
+}
+
+// A Crossfader fades from one stream of audio to another.
+type Crossfader struct {
+	Shape    FadeShape
+	channels int
+	length   int64 // frames in the crossfade
+	pos      int64
+}
+
+// NewCrossfader returns a Crossfader taking d at samplerate, with the given shape for both the fade out and the fade in.
+func NewCrossfader(shape FadeShape, samplerate, channels int, d time.Duration) (*Crossfader, error) {
+	if channels < 1 {
+		return nil, errors.New("NewCrossfader: no channels")
+	}
+	return &Crossfader{Shape: shape, channels: channels, length: durationFrames(d, int32(samplerate))}, nil
+}
+
+// Mix sets out to the next frames of the crossfade from the frames in from to those in to, which must be as long as out. Once the crossfade is over, out is a copy of to. out may be the same slice as from or to.
+func (x *Crossfader) Mix(out, from, to []float64) {
+	for i := 0; i+x.channels <= len(out); i += x.channels {
+		if x.pos >= x.length {
+			copy(out[i:], to[i:len(out)])
+			x.pos += int64((len(out) - i) / x.channels)
+			return
+		}
+		t := (float64(x.pos) + 0.5) / float64(x.length)
+		gin, gout := x.Shape.Gain(t), x.Shape.Gain(1-t)
+		for c := i; c < i+x.channels; c++ {
+			out[c] = from[c]*gout + to[c]*gin
+		}
+		x.pos++
+	}
+}
+
+// Done reports whether the crossfade is over.
+func (x *Crossfader) Done() bool {
+	return x.pos >= x.length
+}
+
+// A Breakpoint sets the gain of a GainEnvelope at a time.
+type Breakpoint struct {
+	Time time.Duration
+	Gain float64 // in dB, which may be -Inf
+}
+
+// A GainEnvelope scales audio by a gain that moves between breakpoints. Between two breakpoints the gain moves evenly in dB, or evenly in amplitude where one of them is -Inf dB; before the first and after the last it holds.
+type GainEnvelope struct {
+	channels int
+	frames   []int64   // breakpoint positions
+	gains    []float64 // breakpoint gains, in dB
+	pos      int64
+	next     int // the first breakpoint after pos
+}
+
+// NewGainEnvelope returns a GainEnvelope for audio at samplerate following points, which are sorted by time.
+func NewGainEnvelope(samplerate, channels int, points []Breakpoint) (*GainEnvelope, error) {
+	if len(points) == 0 {
+		return nil, errors.New("NewGainEnvelope: no breakpoints")
+	}
+	if channels < 1 {
+		return nil, errors.New("NewGainEnvelope: no channels")
+	}
+	p := append([]Breakpoint(nil), points...)
+	sort.SliceStable(p, func(i, j int) bool { return p[i].Time < p[j].Time })
+	e := &GainEnvelope{channels: channels}
+	for _, b := range p {
+		if math.IsNaN(b.Gain) || math.IsInf(b.Gain, 1) {
+			return nil, fmt.Errorf("NewGainEnvelope: bad gain %g at %v", b.Gain, b.Time)
+		}
+		e.frames = append(e.frames, durationFrames(b.Time, int32(samplerate)))
+		e.gains = append(e.gains, b.Gain)
+	}
+	return e, nil
+}
+
+// gain returns the linear gain at frame n, which is not before the last frame asked for.
+func (e *GainEnvelope) gain(n int64) float64 {
+	for e.next < len(e.frames) && e.frames[e.next] <= n {
+		e.next++
+	}
+	switch e.next {
+	case 0:
+		return dbGain(e.gains[0])
+	case len(e.frames):
+		return dbGain(e.gains[len(e.gains)-1])
+	}
+	a, b := e.next-1, e.next
+	x := float64(n-e.frames[a]) / float64(e.frames[b]-e.frames[a])
+	if math.IsInf(e.gains[a], -1) || math.IsInf(e.gains[b], -1) {
+		ga, gb := dbGain(e.gains[a]), dbGain(e.gains[b])
+		return ga + (gb-ga)*x
+	}
+	return dbGain(e.gains[a] + (e.gains[b]-e.gains[a])*x)
+}
+
+func dbGain(db float64) float64 {
+	return math.Pow(10, db/20)
+}
+
+// Process applies the envelope to the next frames of the audio.
+func (e *GainEnvelope) Process(b []float64) {
+	for i := 0; i+e.channels <= len(b); i += e.channels {
+		g := e.gain(e.pos)
+		for c := i; c < i+e.channels; c++ {
+			b[c] *= g
+		}
+		e.pos++
+	}
+}
+
+// A ProcessingWriter passes frames through BlockProcessors on their way to a File.
+type ProcessingWriter struct {
+	File   *File
+	Stages []BlockProcessor
+	buf    []float64
+}
+
+// NewProcessingWriter returns a ProcessingWriter writing to dst, which must be open for writing, through stages in order.
+func NewProcessingWriter(dst *File, stages ...BlockProcessor) *ProcessingWriter {
+	return &ProcessingWriter{File: dst, Stages: stages}
+}
+
+// WriteFrames processes a copy of the frames in b, which is left as it is, and writes them to the file.
+func (w *ProcessingWriter) WriteFrames(b []float64) (written int64, err error) {
+	w.buf = append(w.buf[:0], b...)
+	for _, s := range w.Stages {
+		s.Process(w.buf)
+	}
+	return w.File.WriteFrames(w.buf)
+}
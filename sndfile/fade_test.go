@@ -0,0 +1,126 @@
+package sndfile
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFadeShapes(t *testing.T) {
+	for _, s := range []FadeShape{FadeLinear, FadeEqualPower, FadeLog, FadeSCurve} {
+		if s.Gain(0) != 0 || s.Gain(1) != 1 {
+			t.Errorf("%v fade goes from %g to %g", s, s.Gain(0), s.Gain(1))
+		}
+		for x := 0.1; x < 1; x += 0.1 {
+			if s.Gain(x) <= s.Gain(x-0.1) {
+				t.Errorf("%v fade doesn't rise at %g", s, x)
+			}
+		}
+	}
+	if g := FadeEqualPower.Gain(0.3); math.Abs(g*g+FadeEqualPower.Gain(0.7)*FadeEqualPower.Gain(0.7)-1) > 1e-12 {
+		t.Error("equal power fades don't keep the power")
+	}
+	if g := FadeLog.Gain(0.5); math.Abs(20*math.Log10(g)+30) > 1e-9 {
+		t.Errorf("log fade is %g dB half way, expected -30", 20*math.Log10(g))
+	}
+	if g := FadeSCurve.Gain(0.5); math.Abs(g-0.5) > 1e-12 {
+		t.Errorf("s-curve is %g half way", g)
+	}
+}
+
+func TestFader(t *testing.T) {
+	// 100 stereo frames at 1 kHz with 10 ms fades, processed in uneven blocks
+	b := constFrames(100, 1, 1)
+	f, err := NewFader(FadeLinear, 1000, 2, 100, 10*time.Millisecond, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Process(b[:14])
+	f.Process(b[14:])
+	for n, want := range map[int]float64{0: 0.05, 6: 0.65, 10: 1, 89: 1, 90: 0.95, 99: 0.05} {
+		if math.Abs(b[2*n]-want) > 1e-12 || b[2*n+1] != b[2*n] {
+			t.Errorf("frame %d is %g, %g, expected %g", n, b[2*n], b[2*n+1], want)
+		}
+	}
+
+	x, err := NewCrossfader(FadeEqualPower, 1000, 1, 4*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, to := constFrames(6, 1), constFrames(6, 2)
+	out := make([]float64, 6)
+	x.Mix(out[:3], from[:3], to[:3])
+	if x.Done() {
+		t.Error("crossfade over early")
+	}
+	x.Mix(out[3:], from[3:], to[3:])
+	for n := 0; n < 4; n++ {
+		u := (float64(n) + 0.5) / 4
+		if want := math.Cos(u*math.Pi/2) + 2*math.Sin(u*math.Pi/2); math.Abs(out[n]-want) > 1e-12 {
+			t.Errorf("crossfade frame %d is %g, expected %g", n, out[n], want)
+		}
+	}
+	if !x.Done() || out[4] != 2 || out[5] != 2 {
+		t.Errorf("after the crossfade got %v", out[4:])
+	}
+
+	// with no channels Process and Mix would never get through a block
+	if _, err = NewFader(FadeLinear, 1000, 0, 100, 0, 0); err == nil {
+		t.Error("expected an error for a fader with no channels")
+	}
+	if _, err = NewCrossfader(FadeLinear, 1000, 0, time.Millisecond); err == nil {
+		t.Error("expected an error for a crossfader with no channels")
+	}
+}
+
+func TestGainEnvelope(t *testing.T) {
+	e, err := NewGainEnvelope(1000, 1, []Breakpoint{{time.Second, -20}, {0, 0}, {2 * time.Second, -20}, {3 * time.Second, math.Inf(-1)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := constFrames(4000, 1)
+	for i := 0; i < len(b); i += 777 {
+		end := i + 777
+		if end > len(b) {
+			end = len(b)
+		}
+		e.Process(b[i:end])
+	}
+	for n, want := range map[int]float64{0: 1, 500: math.Pow(10, -0.5), 1000: 0.1, 1500: 0.1, 2500: 0.05, 3000: 0, 3999: 0} {
+		if math.Abs(b[n]-want) > 1e-12 {
+			t.Errorf("frame %d is %g, expected %g", n, b[n], want)
+		}
+	}
+	if _, err = NewGainEnvelope(1000, 1, nil); err == nil {
+		t.Error("expected an error for an empty envelope")
+	}
+	if _, err = NewGainEnvelope(1000, 0, []Breakpoint{{0, 0}}); err == nil {
+		t.Error("expected an error for an envelope with no channels")
+	}
+
+	// the envelope on the way to a file, leaving the caller's frames alone
+	i := Info{Samplerate: 1000, Channels: 1, Format: SF_FORMAT_WAV | SF_FORMAT_FLOAT}
+	f, err := Open("envelope.wav", Write, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, _ = NewGainEnvelope(1000, 1, []Breakpoint{{0, -6}})
+	fader, _ := NewFader(FadeLinear, 1000, 1, 100, 0, 10*time.Millisecond)
+	w := NewProcessingWriter(f, e, fader)
+	b = constFrames(100, 1)
+	if n, err := w.WriteFrames(b); n != 100 || err != nil {
+		t.Fatal("short write", err)
+	}
+	f.Close()
+	if b[99] != 1 {
+		t.Error("WriteFrames changed its input")
+	}
+	if f, err = Open("envelope.wav", Read, &i); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.ReadFrames(b)
+	if g := math.Pow(10, -6.0/20); math.Abs(b[0]-g) > 1e-6 || math.Abs(b[99]-g*0.05) > 1e-6 {
+		t.Errorf("wrote %g and %g", b[0], b[99])
+	}
+}
@@ -0,0 +1,330 @@
+package sndfile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// StreamFormat is the sample rate and channel count of the audio passing between stages of a processing graph.
+type StreamFormat struct {
+	Samplerate int
+	Channels   int
+}
+
+func (s StreamFormat) String() string {
+	return fmt.Sprintf("%d Hz, %d channels", s.Samplerate, s.Channels)
+}
+
+// A Source supplies audio to a processing graph as interleaved float64 frames, normalised to [-1.0, 1.0].
+type Source interface {
+	Format() StreamFormat
+	// Read fills b with the next frames and returns the number read, which is only less than will fit in b at the end of the audio, and 0 after it.
+	Read(b []float64) (int64, error)
+}
+
+// A Processor is a stage of a processing graph, turning the audio of one Source into another.
+type Processor interface {
+	// Connect returns a Source giving the processed audio of in, or an error if the Processor can't take audio in its format.
+	Connect(in Source) (Source, error)
+}
+
+// A Sink takes the audio at the end of a processing graph.
+type Sink interface {
+	Format() StreamFormat
+	Write(b []float64) error
+}
+
+// A Route sends the audio of a Source to a Sink.
+type Route struct {
+	Source Source
+	Sink   Sink
+}
+
+// Chain connects processors one after the other to src, and returns the Source at the end.
+func Chain(src Source, processors ...Processor) (Source, error) {
+	for i, p := range processors {
+		out, err := p.Connect(src)
+		if err != nil {
+			return nil, fmt.Errorf("Chain: stage %d: %v", i, err)
+		}
+		src = out
+	}
+	return src, nil
+}
+
+// Run pulls audio through every route a block of frames at a time, taking a block for each route in turn, until every Source has ended. The formats at the two ends of each route must match. Fan-out branches made by Tee should all be run together, so that none falls far behind. If ctx is cancelled Run stops with its error; the sinks are not closed either way.
+func Run(ctx context.Context, block int, routes ...Route) error {
+	if block <= 0 {
+		block = 4096
+	}
+	bufs := make([][]float64, len(routes))
+	for i, r := range routes {
+		if r.Source.Format() != r.Sink.Format() {
+			return fmt.Errorf("Run: route %d carries %v into a sink taking %v", i, r.Source.Format(), r.Sink.Format())
+		}
+		bufs[i] = make([]float64, block*r.Source.Format().Channels)
+	}
+	done := make([]bool, len(routes))
+	for left := len(routes); left > 0; {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for i, r := range routes {
+			if done[i] {
+				continue
+			}
+			n, err := r.Source.Read(bufs[i])
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				if err = r.Sink.Write(bufs[i][:int(n)*r.Source.Format().Channels]); err != nil {
+					return err
+				}
+			}
+			if n < int64(block) {
+				done[i] = true
+				left--
+			}
+		}
+	}
+	return nil
+}
+
+type fileSource struct {
+	f *File
+}
+
+// NewFileSource returns a Source reading f, which must be open for reading, from its current position. Double normalisation is turned on for f.
+func NewFileSource(f *File) Source {
+	f.SetDoubleNormalization(true)
+	return fileSource{f}
+}
+
+func (s fileSource) Format() StreamFormat {
+	return StreamFormat{int(s.f.Format.Samplerate), int(s.f.Format.Channels)}
+}
+
+func (s fileSource) Read(b []float64) (int64, error) {
+	return readFull(func(b []float64) (int64, error) { return s.f.ReadFrames(b) }, b, int(s.f.Format.Channels))
+}
+
+type fileSink struct {
+	f *File
+}
+
+// NewFileSink returns a Sink writing to f, which must be open for writing. f is not closed when the graph has run.
+func NewFileSink(f *File) Sink {
+	return fileSink{f}
+}
+
+func (s fileSink) Format() StreamFormat {
+	return StreamFormat{int(s.f.Format.Samplerate), int(s.f.Format.Channels)}
+}
+
+func (s fileSink) Write(b []float64) error {
+	n, err := s.f.WriteFrames(b)
+	if err == nil && n != int64(len(b)/int(s.f.Format.Channels)) {
+		err = errors.New("FileSink: short write")
+	}
+	return err
+}
+
+type meterSink struct {
+	m      *LoudnessMeter
+	format StreamFormat
+}
+
+// NewMeterSink returns a Sink feeding m. It takes audio at the sample rate and channel count m was made for.
+func NewMeterSink(m *LoudnessMeter) Sink {
+	return meterSink{m, StreamFormat{m.samplerate, m.channels}}
+}
+
+func (s meterSink) Format() StreamFormat {
+	return s.format
+}
+
+func (s meterSink) Write(b []float64) error {
+	s.m.AddFrames(b)
+	return nil
+}
+
+// readSource is a Source made from a format and a read function, for stages built on the Remixer and Resampler.
+type readSource struct {
+	format StreamFormat
+	read   func([]float64) (int64, error)
+}
+
+func (s readSource) Format() StreamFormat {
+	return s.format
+}
+
+func (s readSource) Read(b []float64) (int64, error) {
+	return readFull(s.read, b, s.format.Channels)
+}
+
+// streamInfo returns the Info the Remixer and Resampler take for audio from in, whose length isn't known.
+func streamInfo(in Source) Info {
+	f := in.Format()
+	return Info{Frames: -1, Samplerate: int32(f.Samplerate), Channels: int32(f.Channels)}
+}
+
+type processorFunc func(in Source) (Source, error)
+
+func (p processorFunc) Connect(in Source) (Source, error) {
+	return p(in)
+}
+
+// Resample returns a Processor that resamples audio to rate, as a Resampler does.
+func Resample(rate int, quality ResampleQuality) Processor {
+	return processorFunc(func(in Source) (Source, error) {
+		r, err := newResampler(in.Read, streamInfo(in), rate, quality)
+		if err != nil {
+			return nil, err
+		}
+		return readSource{StreamFormat{rate, in.Format().Channels}, r.ReadFrames}, nil
+	})
+}
+
+// Remix returns a Processor that remixes audio through m, as a Remixer does. m must have a column for each channel of the audio.
+func Remix(m RemixMatrix) Processor {
+	return processorFunc(func(in Source) (Source, error) {
+		r, err := newRemixerMatrix(in.Read, streamInfo(in), m)
+		if err != nil {
+			return nil, err
+		}
+		return readSource{StreamFormat{in.Format().Samplerate, len(m)}, r.ReadFrames}, nil
+	})
+}
+
+// Gain returns a Processor that scales audio by db decibels.
+func Gain(db float64) Processor {
+	g := math.Pow(10, db/20)
+	return processorFunc(func(in Source) (Source, error) {
+		return readSource{in.Format(), func(b []float64) (int64, error) {
+			n, err := in.Read(b)
+			for i := range b[:int(n)*in.Format().Channels] {
+				b[i] *= g
+			}
+			return n, err
+		}}, nil
+	})
+}
+
+// Blocks returns a Processor that passes audio through p, which was made for audio in format, such as a Fader or GainEnvelope. Connecting it to audio in any other format is an error.
+func Blocks(format StreamFormat, p BlockProcessor) Processor {
+	return processorFunc(func(in Source) (Source, error) {
+		if in.Format() != format {
+			return nil, fmt.Errorf("Blocks: processor for %v given %v", format, in.Format())
+		}
+		return readSource{format, func(b []float64) (int64, error) {
+			n, err := in.Read(b)
+			p.Process(b[:int(n)*format.Channels])
+			return n, err
+		}}, nil
+	})
+}
+
+// tee holds the audio read from a Source that some of its branches have yet to take.
+type tee struct {
+	src     Source
+	pending [][]float64
+	buf     []float64
+	ended   bool
+}
+
+type teeBranch struct {
+	t *tee
+	i int
+}
+
+// Tee splits src into n Sources that each give all of its audio. Audio is held until every branch has read it, so all the branches must be read.
+func Tee(src Source, n int) []Source {
+	t := &tee{src: src, pending: make([][]float64, n)}
+	out := make([]Source, n)
+	for i := range out {
+		out[i] = teeBranch{t, i}
+	}
+	return out
+}
+
+func (b teeBranch) Format() StreamFormat {
+	return b.t.src.Format()
+}
+
+func (b teeBranch) Read(out []float64) (int64, error) {
+	t := b.t
+	channels := t.src.Format().Channels
+	for len(t.pending[b.i]) < len(out) && !t.ended {
+		if len(t.buf) < len(out) {
+			t.buf = make([]float64, len(out))
+		}
+		n, err := t.src.Read(t.buf[:len(out)])
+		if err != nil {
+			return 0, err
+		}
+		if n < int64(len(out)/channels) {
+			t.ended = true
+		}
+		for i := range t.pending {
+			t.pending[i] = append(t.pending[i], t.buf[:int(n)*channels]...)
+		}
+	}
+	n := copy(out, t.pending[b.i]) / channels
+	t.pending[b.i] = t.pending[b.i][n*channels:]
+	return int64(n), nil
+}
+
+type sum struct {
+	srcs   []Source
+	ended  []bool
+	buf    []float64
+	format StreamFormat
+}
+
+// Sum returns a Source that mixes srcs, which must all have the same format, by adding them. It runs until the longest of them ends.
+func Sum(srcs ...Source) (Source, error) {
+	if len(srcs) == 0 {
+		return nil, errors.New("Sum: no sources")
+	}
+	s := &sum{srcs: srcs, ended: make([]bool, len(srcs)), format: srcs[0].Format()}
+	for i, src := range srcs {
+		if src.Format() != s.format {
+			return nil, fmt.Errorf("Sum: source %d is %v, source 0 is %v", i, src.Format(), s.format)
+		}
+	}
+	return s, nil
+}
+
+func (s *sum) Format() StreamFormat {
+	return s.format
+}
+
+func (s *sum) Read(b []float64) (int64, error) {
+	if len(s.buf) < len(b) {
+		s.buf = make([]float64, len(b))
+	}
+	for i := range b {
+		b[i] = 0
+	}
+	var most int64
+	for i, src := range s.srcs {
+		if s.ended[i] {
+			continue
+		}
+		n, err := src.Read(s.buf[:len(b)])
+		if err != nil {
+			return 0, err
+		}
+		if n < int64(len(b)/s.format.Channels) {
+			s.ended[i] = true
+		}
+		for j, v := range s.buf[:int(n)*s.format.Channels] {
+			b[j] += v
+		}
+		most = max64(most, n)
+	}
+	return most, nil
+}
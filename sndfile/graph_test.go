@@ -0,0 +1,101 @@
+package sndfile
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestGraph(t *testing.T) {
+	float := SF_FORMAT_WAV | SF_FORMAT_FLOAT
+	writeFloats(t, "grapha.wav", Info{Samplerate: 48000, Channels: 2, Format: float}, tone(48000, 2, -20, 0, 1), nil)
+	writeFloats(t, "graphb.wav", Info{Samplerate: 48000, Channels: 2, Format: float}, constFrames(60000, 0.25, 0), nil)
+	var i Info
+	a, err := Open("grapha.wav", Read, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	ref, _ := a.Loudness(context.Background())
+
+	// 6 dB down and at half the rate, to a file and a meter at once
+	src, err := Chain(NewFileSource(a), Gain(-6), Resample(24000, ResampleMedium))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src.Format() != (StreamFormat{24000, 2}) {
+		t.Fatalf("chain gives %v", src.Format())
+	}
+	out := Info{Samplerate: 24000, Channels: 2, Format: float}
+	dst, err := Open("graphdst.wav", Write, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, _ := NewLoudnessMeter(24000, 2, nil)
+	branches := Tee(src, 2)
+	err = Run(context.Background(), 1000, Route{branches[0], NewFileSink(dst)}, Route{branches[1], NewMeterSink(m)})
+	dst.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := m.Integrated(); math.Abs(l-(ref.Integrated-6)) > 0.1 {
+		t.Errorf("metered %g LUFS, expected %g", l, ref.Integrated-6)
+	}
+	if dst, err = Open("graphdst.wav", Read, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Frames != 24000 {
+		t.Errorf("wrote %d frames, expected 24000", out.Frames)
+	}
+	dst.Close()
+
+	// two sources of different lengths mixed together, for as long as the longer
+	a.Seek(0, Set)
+	b, err := Open("graphb.wav", Read, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	mix, err := Sum(NewFileSource(a), NewFileSource(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.Samplerate = 48000
+	if dst, err = Open("graphdst.wav", Write, &out); err != nil {
+		t.Fatal(err)
+	}
+	err = Run(context.Background(), 0, Route{mix, NewFileSink(dst)})
+	dst.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dst, err = Open("graphdst.wav", Read, &out); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]float64, 2*out.Frames)
+	dst.ReadFrames(got)
+	dst.Close()
+	want := tone(48000, 2, -20, 0, 1)
+	if out.Frames != 60000 || math.Abs(got[2*100]-(float64(float32(want[2*100]))+0.25)) > 1e-6 || got[2*50000] != 0.25 {
+		t.Errorf("sum has %d frames, with %g at frame 100 and %g at 50000", out.Frames, got[2*100], got[2*50000])
+	}
+
+	// formats are checked where stages meet, and cancelling stops the run
+	a.Seek(0, Set)
+	mono, _ := NewFader(FadeLinear, 48000, 1, 100, 0, 0)
+	if _, err = Chain(NewFileSource(a), Blocks(StreamFormat{48000, 1}, mono)); err == nil {
+		t.Error("expected an error connecting a mono stage to stereo audio")
+	}
+	if dst, err = Open("graphdst.wav", Write, &out); err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	if err = Run(context.Background(), 0, Route{mix, NewMeterSink(m)}); err == nil {
+		t.Error("expected an error running 48 kHz audio into a 24 kHz sink")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err = Run(ctx, 0, Route{NewFileSource(a), NewFileSink(dst)}); err != context.Canceled {
+		t.Errorf("cancelled run returned %v", err)
+	}
+}
@@ -0,0 +1,286 @@
+package sndfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// A ChannelPosition is the speaker a channel is meant for, one of the ChannelMap constants. The constants are untyped, so they can be used both as ChannelPositions and in the []int32 channel maps taken by SetChannelMapInfo.
+type ChannelPosition int32
+
+var channelPositionNames = map[ChannelPosition]string{
+	ChannelMapInvalid:            "Invalid",
+	ChannelMapMono:               "Mono",
+	ChannelMapLeft:               "Left",
+	ChannelMapRight:              "Right",
+	ChannelMapCenter:             "Center",
+	ChannelMapFrontLeft:          "FrontLeft",
+	ChannelMapFrontRight:         "FrontRight",
+	ChannelMapFrontCenter:        "FrontCenter",
+	ChannelMapRearCenter:         "RearCenter",
+	ChannelMapRearLeft:           "RearLeft",
+	ChannelMapRearRight:          "RearRight",
+	ChannelMapLfe:                "Lfe",
+	ChannelMapFrontLeftOfCenter:  "FrontLeftOfCenter",
+	ChannelMapFrontRightOfCenter: "FrontRightOfCenter",
+	ChannelMapSideLeft:           "SideLeft",
+	ChannelMapSideRight:          "SideRight",
+	ChannelMapTopCenter:          "TopCenter",
+	ChannelMapTopFrontLeft:       "TopFrontLeft",
+	ChannelMapTopFrontRight:      "TopFrontRight",
+	ChannelMapTopFrontCenter:     "TopFrontCenter",
+	ChannelMapTopRearLeft:        "TopRearLeft",
+	ChannelMapTopRearRight:       "TopRearRight",
+	ChannelMapTopRearCenter:      "TopRearCenter",
+	ChannelMapAmbisonicBW:        "AmbisonicBW",
+	ChannelMapAmbisonicBX:        "AmbisonicBX",
+	ChannelMapAmbisonicBY:        "AmbisonicBY",
+	ChannelMapAmbisonicBZ:        "AmbisonicBZ",
+}
+
+// String returns the name of the position, as in its ChannelMap constant.
+func (p ChannelPosition) String() string {
+	if s, ok := channelPositionNames[p]; ok {
+		return s
+	}
+	return fmt.Sprintf("ChannelPosition(%d)", int32(p))
+}
+
+// A Layout is an ordered set of speaker positions, one for each channel of a file.
+type Layout struct {
+	Name      string // empty for layouts that aren't one of the predefined ones
+	Positions []ChannelPosition
+}
+
+// The predefined layouts. Their channels are in WAV order, and use the positions libsndfile reports when reading a WAVEX channel mask or CAF layout tag, so they compare equal to what is read back. Layout71 is the ITU 7.1 with side and rear surrounds, and Layout714 adds four height speakers to it.
+var (
+	LayoutMono       = Layout{"mono", []ChannelPosition{ChannelMapMono}}
+	LayoutStereo     = Layout{"stereo", []ChannelPosition{ChannelMapLeft, ChannelMapRight}}
+	LayoutLCR        = Layout{"LCR", []ChannelPosition{ChannelMapLeft, ChannelMapRight, ChannelMapCenter}}
+	LayoutQuad       = Layout{"quad", []ChannelPosition{ChannelMapLeft, ChannelMapRight, ChannelMapRearLeft, ChannelMapRearRight}}
+	Layout50         = Layout{"5.0", []ChannelPosition{ChannelMapLeft, ChannelMapRight, ChannelMapCenter, ChannelMapRearLeft, ChannelMapRearRight}}
+	Layout51         = Layout{"5.1", []ChannelPosition{ChannelMapLeft, ChannelMapRight, ChannelMapCenter, ChannelMapLfe, ChannelMapRearLeft, ChannelMapRearRight}}
+	Layout71         = Layout{"7.1", []ChannelPosition{ChannelMapLeft, ChannelMapRight, ChannelMapCenter, ChannelMapLfe, ChannelMapRearLeft, ChannelMapRearRight, ChannelMapSideLeft, ChannelMapSideRight}}
+	Layout714        = Layout{"7.1.4", []ChannelPosition{ChannelMapLeft, ChannelMapRight, ChannelMapCenter, ChannelMapLfe, ChannelMapRearLeft, ChannelMapRearRight, ChannelMapSideLeft, ChannelMapSideRight, ChannelMapTopFrontLeft, ChannelMapTopFrontRight, ChannelMapTopRearLeft, ChannelMapTopRearRight}}
+	LayoutAmbisonicB = Layout{"ambisonic B", []ChannelPosition{ChannelMapAmbisonicBW, ChannelMapAmbisonicBX, ChannelMapAmbisonicBY, ChannelMapAmbisonicBZ}}
+)
+
+// Layouts lists the predefined layouts, for looking them up by name or positions.
+var Layouts = []Layout{LayoutMono, LayoutStereo, LayoutLCR, LayoutQuad, Layout50, Layout51, Layout71, Layout714, LayoutAmbisonicB}
+
+// LayoutOf returns the layout of a channel map as returned by GetChannelMapInfo, named if it matches one of the predefined layouts. Left, Right and Center match FrontLeft, FrontRight and FrontCenter.
+func LayoutOf(channelMap []int32) Layout {
+	l := Layout{Positions: make([]ChannelPosition, len(channelMap))}
+	for i, p := range channelMap {
+		l.Positions[i] = ChannelPosition(p)
+	}
+	for _, d := range Layouts {
+		if d.Equal(l) {
+			l.Name = d.Name
+			break
+		}
+	}
+	return l
+}
+
+// String returns the layout's name, or its positions if it has none.
+func (l Layout) String() string {
+	if l.Name != "" {
+		return l.Name
+	}
+	s := make([]string, len(l.Positions))
+	for i, p := range l.Positions {
+		s[i] = p.String()
+	}
+	return "(" + strings.Join(s, " ") + ")"
+}
+
+// Equal reports whether two layouts have the same speakers in the same order, taking Left, Right and Center to be FrontLeft, FrontRight and FrontCenter. Names are not compared.
+func (l Layout) Equal(m Layout) bool {
+	return equalChannelMaps(l.ChannelMap(), m.ChannelMap())
+}
+
+// ChannelMap returns the layout as a channel map for SetChannelMapInfo.
+func (l Layout) ChannelMap() []int32 {
+	cm := make([]int32, len(l.Positions))
+	for i, p := range l.Positions {
+		cm[i] = int32(p)
+	}
+	return cm
+}
+
+// The speakers of the WAVE_FORMAT_EXTENSIBLE dwChannelMask, in bit order, as libsndfile names them.
+var wavexMaskPositions = []ChannelPosition{
+	ChannelMapLeft,
+	ChannelMapRight,
+	ChannelMapCenter,
+	ChannelMapLfe,
+	ChannelMapRearLeft,
+	ChannelMapRearRight,
+	ChannelMapFrontLeftOfCenter,
+	ChannelMapFrontRightOfCenter,
+	ChannelMapRearCenter,
+	ChannelMapSideLeft,
+	ChannelMapSideRight,
+	ChannelMapTopCenter,
+	ChannelMapTopFrontLeft,
+	ChannelMapTopFrontCenter,
+	ChannelMapTopFrontRight,
+	ChannelMapTopRearLeft,
+	ChannelMapTopRearCenter,
+	ChannelMapTopRearRight,
+}
+
+// wavexPosition returns the position libsndfile uses for p in WAVEX files, where the front speakers are Left, Right and Center and a mono channel is the centre speaker.
+func wavexPosition(p ChannelPosition) ChannelPosition {
+	switch canonicalPosition(int32(p)) {
+	case ChannelMapFrontLeft:
+		return ChannelMapLeft
+	case ChannelMapFrontRight:
+		return ChannelMapRight
+	case ChannelMapFrontCenter:
+		return ChannelMapCenter
+	}
+	return p
+}
+
+// WAVEXMask returns the layout as a WAVEX dwChannelMask. WAVEX files must hold their channels in the order of the mask bits, so it is an error for the layout to be in any other order or to hold a position the mask has no bit for, such as the Ambisonic ones.
+func (l Layout) WAVEXMask() (uint32, error) {
+	var mask uint32
+	last := -1
+	for i, p := range l.Positions {
+		w := wavexPosition(p)
+		bit := -1
+		for b, mp := range wavexMaskPositions {
+			if mp == w {
+				bit = b
+				break
+			}
+		}
+		if bit < 0 {
+			return 0, fmt.Errorf("WAVEXMask: channel %d (%v) has no channel mask bit", i, p)
+		}
+		if bit <= last {
+			return 0, fmt.Errorf("WAVEXMask: channel %d (%v) is out of WAVEX order", i, p)
+		}
+		mask |= 1 << uint(bit)
+		last = bit
+	}
+	return mask, nil
+}
+
+// LayoutFromWAVEXMask returns the layout of a WAVEX file with the given number of channels and dwChannelMask. As in libsndfile, channels beyond the bits set in the mask are given ChannelMapInvalid and bits beyond the number of channels are ignored.
+func LayoutFromWAVEXMask(mask uint32, channels int) Layout {
+	l := Layout{Positions: make([]ChannelPosition, 0, channels)}
+	for b, p := range wavexMaskPositions {
+		if len(l.Positions) == channels {
+			break
+		}
+		if mask&(1<<uint(b)) != 0 {
+			l.Positions = append(l.Positions, p)
+		}
+	}
+	for len(l.Positions) < channels {
+		l.Positions = append(l.Positions, ChannelMapInvalid)
+	}
+	return LayoutOf(l.ChannelMap())
+}
+
+// CAF layout tags have the layout number in the top 16 bits and the channel count in the bottom 16.
+var cafLayoutTags = []struct {
+	tag    uint32
+	layout Layout
+}{
+	{100<<16 | 1, LayoutMono},       // kAudioChannelLayoutTag_Mono
+	{101<<16 | 2, LayoutStereo},     // kAudioChannelLayoutTag_Stereo
+	{107<<16 | 4, LayoutAmbisonicB}, // kAudioChannelLayoutTag_Ambisonic_B_Format
+	{108<<16 | 4, LayoutQuad},       // kAudioChannelLayoutTag_Quadraphonic
+	{113<<16 | 3, LayoutLCR},        // kAudioChannelLayoutTag_MPEG_3_0_A
+	{117<<16 | 5, Layout50},         // kAudioChannelLayoutTag_MPEG_5_0_A
+	{121<<16 | 6, Layout51},         // kAudioChannelLayoutTag_MPEG_5_1_A
+	{128<<16 | 8, Layout71},         // kAudioChannelLayoutTag_MPEG_7_1_C
+	{192<<16 | 12, Layout714},       // kAudioChannelLayoutTag_Atmos_7_1_4
+}
+
+// CAFLayoutTag returns the Core Audio layout tag for the layout, and false if there is none for its speakers in its order. libsndfile only writes tags for layouts of up to six channels, so the tags for 7.1 and 7.1.4 are for writers of their own.
+func (l Layout) CAFLayoutTag() (uint32, bool) {
+	for _, t := range cafLayoutTags {
+		if t.layout.Equal(l) {
+			return t.tag, true
+		}
+	}
+	return 0, false
+}
+
+// LayoutFromCAFTag returns the layout for a Core Audio layout tag, and false if it isn't one of the predefined layouts.
+func LayoutFromCAFTag(tag uint32) (Layout, bool) {
+	for _, t := range cafLayoutTags {
+		if t.tag == tag {
+			return t.layout, true
+		}
+	}
+	return Layout{}, false
+}
+
+// DefaultLayout returns the layout assumed for a file of the given number of channels that has no channel map, and false if there is none.
+func DefaultLayout(channels int) (Layout, bool) {
+	for _, l := range []Layout{LayoutMono, LayoutStereo, LayoutLCR, LayoutQuad, Layout50, Layout51, Layout71, Layout714} {
+		if len(l.Positions) == channels {
+			return l, true
+		}
+	}
+	return Layout{}, false
+}
+
+// Layout returns the layout of the file from its channel map.
+func (f *File) Layout() (Layout, error) {
+	cm, err := f.GetChannelMapInfo()
+	if err != nil {
+		return Layout{}, err
+	}
+	return LayoutOf(cm), nil
+}
+
+// SetLayout sets the channel map of a file open for writing, before any audio is written. For WAVEX and RF64 files the layout's positions are changed to the ones libsndfile uses for the channel mask, as WAVEXMask describes, and an Ambisonic B layout marks the file as B format instead.
+func (f *File) SetLayout(l Layout) error {
+	if len(l.Positions) != int(f.Format.Channels) {
+		return fmt.Errorf("SetLayout: layout %v has %d channels, file has %d", l, len(l.Positions), f.Format.Channels)
+	}
+	major := f.Format.Format & SF_FORMAT_TYPEMASK
+	if major != SF_FORMAT_WAVEX && major != SF_FORMAT_RF64 {
+		return f.SetChannelMapInfo(l.ChannelMap())
+	}
+	if l.Equal(LayoutAmbisonicB) {
+		if f.WavexSetAmbisonic(AmbisonicBFormat) != AmbisonicBFormat {
+			return errors.New("SetLayout: couldn't mark file as Ambisonic B format")
+		}
+		return nil
+	}
+	if _, err := l.WAVEXMask(); err != nil {
+		return err
+	}
+	cm := make([]int32, len(l.Positions))
+	for i, p := range l.Positions {
+		cm[i] = int32(wavexPosition(p))
+	}
+	return f.SetChannelMapInfo(cm)
+}
+
+// OpenLayout opens a new file for writing as Open does, and sets its channel map to the layout. If info.Channels is zero it is taken from the layout. This is the way to write a WAVEX file whose channel mask describes its speakers, as libsndfile otherwise fills the mask in from the channel count, with no mask at all for some counts and 7.1 with front centre speakers instead of side surrounds for eight channels.
+func OpenLayout(name string, info *Info, l Layout) (*File, error) {
+	if info.Channels == 0 {
+		info.Channels = int32(len(l.Positions))
+	}
+	f, err := Open(name, Write, info)
+	if err != nil {
+		return f, err
+	}
+	if err = f.SetLayout(l); err != nil {
+		f.Close()
+		os.Remove(name)
+		return nil, err
+	}
+	return f, nil
+}
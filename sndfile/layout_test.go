@@ -0,0 +1,123 @@
+package sndfile
+
+import (
+	"encoding/binary"
+	"os"
+	"testing"
+)
+
+func TestLayoutMasks(t *testing.T) {
+	cases := []struct {
+		l    Layout
+		mask uint32
+	}{
+		{LayoutMono, 0x4},
+		{LayoutStereo, 0x3},
+		{LayoutLCR, 0x7},
+		{LayoutQuad, 0x33},
+		{Layout50, 0x37},
+		{Layout51, 0x3f},
+		{Layout71, 0x63f},
+		{Layout714, 0x2d63f},
+	}
+	for _, c := range cases {
+		mask, err := c.l.WAVEXMask()
+		if err != nil || mask != c.mask {
+			t.Errorf("%v: got mask %#x %v, expected %#x", c.l, mask, err, c.mask)
+		}
+		back := LayoutFromWAVEXMask(mask, len(c.l.Positions))
+		if !back.Equal(c.l) || back.Name != c.l.Name {
+			t.Errorf("%v: mask %#x read back as %v", c.l, mask, back)
+		}
+		tag, ok := c.l.CAFLayoutTag()
+		if !ok || int(tag&0xffff) != len(c.l.Positions) {
+			t.Errorf("%v: got CAF tag %#x %v", c.l, tag, ok)
+		}
+		if l, ok := LayoutFromCAFTag(tag); !ok || l.Name != c.l.Name {
+			t.Errorf("%v: CAF tag %#x read back as %v", c.l, tag, l)
+		}
+	}
+
+	swapped := Layout{Positions: []ChannelPosition{ChannelMapRight, ChannelMapLeft}}
+	if _, err := swapped.WAVEXMask(); err == nil {
+		t.Error("expected an error for channels out of WAVEX order")
+	}
+	if _, err := LayoutAmbisonicB.WAVEXMask(); err == nil {
+		t.Error("expected an error for Ambisonic channels in a mask")
+	}
+	if s := swapped.String(); s != "(Right Left)" {
+		t.Errorf("got %q for an unnamed layout", s)
+	}
+	if s := ChannelPosition(ChannelMapLfe).String(); s != "Lfe" {
+		t.Errorf("got %q for the LFE position", s)
+	}
+	if l := LayoutOf([]int32{ChannelMapFrontLeft, ChannelMapFrontRight}); l.Name != "stereo" {
+		t.Errorf("front left and right should be stereo, got %v", l)
+	}
+	if l := LayoutFromWAVEXMask(0x3, 3); l.Positions[2] != ChannelMapInvalid {
+		t.Errorf("channel beyond the mask should be invalid, got %v", l)
+	}
+}
+
+func TestOpenLayout(t *testing.T) {
+	i := Info{Samplerate: 48000, Format: SF_FORMAT_WAVEX | SF_FORMAT_PCM_16}
+	f, err := OpenLayout("layout71.wav", &i, Layout71)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.Channels != 8 {
+		t.Errorf("expected 8 channels from the layout, got %d", i.Channels)
+	}
+	f.WriteFrames(make([]int16, 8*100))
+	f.Close()
+
+	// libsndfile's own mask for 8 channels would be 0xff
+	r, err := os.Open("layout71.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := scanChunks(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmtChunk := c.find("fmt ")
+	var mask uint32
+	r.Seek(fmtChunk.data+20, os.SEEK_SET)
+	binary.Read(r, binary.LittleEndian, &mask)
+	r.Close()
+	if mask != 0x63f {
+		t.Errorf("channel mask is %#x, expected 0x63f", mask)
+	}
+
+	f, err = Open("layout71.wav", Read, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := f.Layout()
+	f.Close()
+	if err != nil || l.Name != "7.1" {
+		t.Errorf("read back layout %v %v", l, err)
+	}
+
+	i = Info{Samplerate: 48000, Format: SF_FORMAT_WAVEX | SF_FORMAT_PCM_16}
+	if _, err = OpenLayout("layoutbad.wav", &i, Layout{Positions: []ChannelPosition{ChannelMapRight, ChannelMapLeft}}); err == nil {
+		t.Error("expected an error for a layout out of WAVEX order")
+	}
+	if _, err = os.Stat("layoutbad.wav"); err == nil {
+		t.Error("file with a bad layout was left behind")
+	}
+
+	i = Info{Samplerate: 48000, Format: SF_FORMAT_WAVEX | SF_FORMAT_FLOAT}
+	if f, err = OpenLayout("layoutambi.wav", &i, LayoutAmbisonicB); err != nil {
+		t.Fatal(err)
+	}
+	f.WriteFrames(make([]float32, 4*100))
+	f.Close()
+	if f, err = Open("layoutambi.wav", Read, &i); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if f.WavexGetAmbisonic() != AmbisonicBFormat {
+		t.Error("file not marked as Ambisonic B format")
+	}
+}
@@ -0,0 +1,112 @@
+package sndfile
+
+import "math"
+
+// A limiter keeps interleaved audio below a true peak ceiling with a gain that looks ahead of the audio, so that it has come down smoothly before a peak arrives. The gain needed at each frame is taken from the oversampled signal; it is held at its lowest over the attack window ahead, averaged over the same window, which keeps it at or below what every frame needs, and recovers at the release rate. The output lags the input by the attack window plus the oversampling filter's delay.
+type limiter struct {
+	channels int
+	ceiling  float64 // linear
+	window   int     // attack and lookahead in frames
+	release  float64 // fraction of the way back to the needed gain each frame
+	tp       *truePeakMeter
+	delay    []float64  // input frames not yet output, oldest first
+	needed   []minEntry // candidates for the lowest needed gain in the window, in frame order with rising gains
+	held     []float64  // the last window held gains, as a ring
+	heldSum  float64
+	gain     float64
+	n        int64 // frames of needed gain computed
+	reduced  bool  // the gain has gone below 1
+	out      []float64
+}
+
+type minEntry struct {
+	frame int64
+	gain  float64
+}
+
+// newLimiter returns a limiter with a 5 ms attack and a 50 ms release.
+func newLimiter(channels, samplerate int, ceiling float64) *limiter {
+	w := samplerate / 200
+	if w < 1 {
+		w = 1
+	}
+	return &limiter{
+		channels: channels,
+		ceiling:  ceiling,
+		window:   w,
+		release:  1 - math.Exp(-1/(0.05*float64(samplerate))),
+		tp:       newTruePeakMeter(channels),
+		held:     make([]float64, w),
+		gain:     1,
+	}
+}
+
+// process takes interleaved frames and returns the limited frames that are ready, which are valid until the next call.
+func (l *limiter) process(in []float64) []float64 {
+	l.out = l.out[:0]
+	for i := 0; i+l.channels <= len(in); i += l.channels {
+		l.push(in[i:i+l.channels], true)
+	}
+	return l.out
+}
+
+// finish returns the frames still held back at the end of the audio.
+func (l *limiter) finish() []float64 {
+	l.out = l.out[:0]
+	silence := make([]float64, l.channels)
+	for len(l.delay) > 0 {
+		l.push(silence, false)
+	}
+	return l.out
+}
+
+// push takes a frame, and outputs the frame one window and the filter delay before it if that is ready. The silence pushed while finishing is not kept for output.
+func (l *limiter) push(frame []float64, keep bool) {
+	if keep {
+		l.delay = append(l.delay, frame...)
+	}
+
+	var peak float64
+	for c, s := range frame {
+		peak = math.Max(peak, l.tp.push(c, s))
+	}
+	l.tp.frames++
+	if l.tp.frames <= truePeakHalf {
+		return
+	}
+	need := 1.0
+	if peak > l.ceiling {
+		need = l.ceiling / peak
+	}
+
+	// the lowest needed gain from this frame back over the window
+	k := l.n
+	l.n++
+	for len(l.needed) > 0 && l.needed[len(l.needed)-1].gain >= need {
+		l.needed = l.needed[:len(l.needed)-1]
+	}
+	l.needed = append(l.needed, minEntry{k, need})
+	if l.needed[0].frame <= k-int64(l.window) {
+		l.needed = l.needed[1:]
+	}
+	h := int(k % int64(l.window))
+	l.heldSum += l.needed[0].gain - l.held[h]
+	l.held[h] = l.needed[0].gain
+
+	// the held gains now cover the window ending at the frame one window back, which can be output
+	if k < int64(l.window-1) || len(l.delay) == 0 {
+		return
+	}
+	g := l.heldSum / float64(l.window)
+	if g > l.gain {
+		g = l.gain + (g-l.gain)*l.release
+	}
+	if g < 1 {
+		l.reduced = true
+	}
+	l.gain = g
+	for _, s := range l.delay[:l.channels] {
+		l.out = append(l.out, s*g)
+	}
+	l.delay = l.delay[l.channels:]
+}
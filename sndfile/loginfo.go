@@ -0,0 +1,209 @@
+package sndfile
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// A LogEntry is one line of the log libsndfile writes while parsing a file header, as returned by GetLogInfo.
+type LogEntry struct {
+	Depth    int    // indentation, which follows the nesting of chunks and their fields
+	Key      string // the text before the colon, or the whole line if it has none. Chunk ids keep their trailing spaces.
+	Value    string // the text after the colon
+	Chunk    string // the chunk id if the line describes a chunk, otherwise empty
+	Offset   int64  // offset of the chunk in the file if known, otherwise -1. Only Diagnose fills this in.
+	Length   int64  // length of the chunk according to its header, -1 if not a chunk
+	Warning  string // libsndfile's complaint, if the line is one
+	Expected int64  // for complaints of the form "value (should be n)", n; otherwise -1
+}
+
+// ParseLogInfo splits a log from GetLogInfo into entries.
+func ParseLogInfo(log string) (entries []LogEntry) {
+	for _, line := range strings.Split(log, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		e := LogEntry{Offset: -1, Length: -1, Expected: -1}
+		trimmed := strings.TrimLeft(line, " \t")
+		e.Depth = len(line) - len(trimmed)
+		if strings.HasPrefix(trimmed, "***") {
+			trimmed = strings.TrimSpace(strings.TrimLeft(trimmed, "*"))
+			e.Warning = trimmed
+		}
+		if i := strings.Index(trimmed, "should be"); i >= 0 {
+			e.Warning = trimmed
+			n := strings.TrimLeft(trimmed[i+len("should be"):], " ")
+			if v, err := strconv.ParseInt(leadingNumber(n), 10, 64); err == nil {
+				e.Expected = v
+			}
+		}
+		i := strings.Index(trimmed, " : ")
+		if i >= 0 {
+			e.Key, e.Value = trimmed[:i], strings.TrimSpace(trimmed[i+3:])
+		} else {
+			e.Key = strings.TrimSpace(trimmed)
+		}
+		if i >= 0 && isLogChunk(e.Key, e.Value) {
+			e.Chunk = e.Key
+			if v, err := strconv.ParseInt(leadingNumber(e.Value), 10, 64); err == nil {
+				e.Length = v
+			}
+		} else {
+			e.Key = strings.TrimRight(e.Key, " ")
+		}
+		entries = append(entries, e)
+	}
+	return
+}
+
+func leadingNumber(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
+
+// libsndfile logs each chunk as its four character id, then the length or, for text chunks, the text.
+func isLogChunk(key, value string) bool {
+	if len(key) != 4 || !printableID([]byte(key)) || key[0] == ' ' {
+		return false
+	}
+	if leadingNumber(value) != "" {
+		return true
+	}
+	for _, c := range key {
+		if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == ' ') {
+			return false
+		}
+	}
+	return true
+}
+
+// logWarnings returns the complaints in a log.
+func logWarnings(entries []LogEntry) (w []string) {
+	for _, e := range entries {
+		if e.Warning != "" {
+			w = append(w, e.Warning)
+		}
+	}
+	return
+}
+
+// OpenError is returned when libsndfile can't open a file. It carries the log of the attempt, which often says more than the error message does.
+type OpenError struct {
+	Msg string
+	Log []LogEntry
+}
+
+func (e *OpenError) Error() string {
+	return e.Msg
+}
+
+// newOpenError builds an OpenError for a File that failed to open.
+func newOpenError(msg string, f *File) error {
+	s, _ := f.GetLogInfo()
+	return &OpenError{Msg: msg, Log: ParseLogInfo(s)}
+}
+
+// A Diagnosis is everything Diagnose could find out about a file.
+type Diagnosis struct {
+	Path      string
+	Size      int64
+	Info      Info          // valid if OpenError is nil
+	OpenError error         // why libsndfile couldn't open the file, if it couldn't
+	Log       []LogEntry    // libsndfile's log, with chunk offsets filled in where the chunks could be found
+	Warnings  []string      // the complaints in the log
+	Problems  HeaderProblem // header problems Repair would fix, for WAV and AIFF files
+	RepairErr error         // why the file couldn't be checked for header problems
+}
+
+// Diagnose opens the file at path with libsndfile and checks it for problems without changing it. It only fails if the file can't be read at all; problems with its contents are reported in the Diagnosis.
+func Diagnose(path string) (d *Diagnosis, err error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	d = &Diagnosis{Path: path, Size: st.Size()}
+	f, err := Open(path, Read, &d.Info)
+	if err != nil {
+		d.OpenError = err
+		if oe, ok := err.(*OpenError); ok {
+			d.Log = oe.Log
+		}
+	} else {
+		s, _ := f.GetLogInfo()
+		d.Log = ParseLogInfo(s)
+		f.Close()
+	}
+	d.Warnings = logWarnings(d.Log)
+
+	if r, err := os.Open(path); err == nil {
+		if c, err := scanChunks(r); err == nil {
+			locateChunks(d.Log, c)
+		}
+		r.Close()
+	}
+	report, err := Repair(path, &RepairOptions{DryRun: true})
+	d.Problems, d.RepairErr = report.Problems, err
+	return d, nil
+}
+
+// locateChunks matches the chunks in a log with those found in the file, in order.
+func locateChunks(entries []LogEntry, c *chunkFile) {
+	next := 0
+	for i := range entries {
+		e := &entries[i]
+		if e.Chunk == "" {
+			continue
+		}
+		switch e.Chunk {
+		case "RIFF", "RIFX", "RF64", "FORM", "caff":
+			e.Offset = 0
+			continue
+		}
+		for j := next; j < len(c.chunks); j++ {
+			if c.chunks[j].id == e.Chunk {
+				e.Offset = c.chunks[j].pos
+				next = j + 1
+				break
+			}
+		}
+	}
+}
+
+// String formats the diagnosis as a report for people.
+func (d *Diagnosis) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d bytes\n", d.Path, d.Size)
+	if d.OpenError != nil {
+		fmt.Fprintf(&b, "libsndfile can't open it: %v\n", d.OpenError)
+	} else {
+		fmt.Fprintf(&b, "format %#x, %d channels at %d Hz, %d frames\n", d.Info.Format, d.Info.Channels, d.Info.Samplerate, d.Info.Frames)
+	}
+	if d.RepairErr == nil {
+		fmt.Fprintf(&b, "header problems: %v\n", d.Problems)
+	} else {
+		fmt.Fprintf(&b, "header not checked: %v\n", d.RepairErr)
+	}
+	for _, w := range d.Warnings {
+		fmt.Fprintf(&b, "warning: %s\n", w)
+	}
+	for _, e := range d.Log {
+		if e.Chunk == "" {
+			continue
+		}
+		if e.Offset >= 0 {
+			fmt.Fprintf(&b, "chunk %q at %d", e.Chunk, e.Offset)
+		} else {
+			fmt.Fprintf(&b, "chunk %q", e.Chunk)
+		}
+		if e.Length >= 0 {
+			fmt.Fprintf(&b, ", %d bytes", e.Length)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,88 @@
+package sndfile
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseLogInfo(t *testing.T) {
+	log := "File : x.aiff\nLength : 4066\nFORM : 4058 (should be 4062)\n AIFF\n COMM : 18\n  Sample Rate : 8000\n NAME : ramp\n SSND : 4008\n  Offset     : 0\n*** 'data' chunk should be an even number of bytes in length.\n"
+	entries := ParseLogInfo(log)
+	if len(entries) != 10 {
+		t.Fatalf("expected 10 entries, got %d: %v", len(entries), entries)
+	}
+	want := []struct {
+		key, chunk string
+		depth      int
+		length     int64
+	}{
+		{"File", "", 0, -1},
+		{"Length", "", 0, -1},
+		{"FORM", "FORM", 0, 4058},
+		{"AIFF", "", 1, -1},
+		{"COMM", "COMM", 1, 18},
+		{"Sample Rate", "", 2, -1},
+		{"NAME", "NAME", 1, -1},
+		{"SSND", "SSND", 1, 4008},
+		{"Offset", "", 2, -1},
+	}
+	for n, w := range want {
+		e := entries[n]
+		if e.Key != w.key || e.Chunk != w.chunk || e.Depth != w.depth || e.Length != w.length {
+			t.Errorf("entry %d is %+v, expected %+v", n, e, w)
+		}
+	}
+	if entries[2].Warning == "" || entries[2].Expected != 4062 {
+		t.Errorf("size mismatch not picked up: %+v", entries[2])
+	}
+	if w := entries[9].Warning; !strings.HasPrefix(w, "'data' chunk should be") || entries[9].Expected != -1 {
+		t.Errorf("warning not picked up: %+v", entries[9])
+	}
+}
+
+func TestOpenError(t *testing.T) {
+	os.WriteFile("notaudio.wav", []byte("RIFF\x04\x00\x00\x00WAVEjunk"), 0644)
+	var i Info
+	_, err := Open("notaudio.wav", Read, &i)
+	oe, ok := err.(*OpenError)
+	if !ok {
+		t.Fatalf("expected an OpenError, got %T %v", err, err)
+	}
+	if len(oe.Log) == 0 || oe.Log[0].Value != "notaudio.wav" {
+		t.Errorf("log not attached to error: %v", oe.Log)
+	}
+}
+
+func TestDiagnose(t *testing.T) {
+	writeRamp(t, "diagnose.wav", Info{Samplerate: 8000, Channels: 1, Format: SF_FORMAT_WAV | SF_FORMAT_PCM_16}, 100)
+	f, err := os.OpenFile("diagnose.wav", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("trailing"))
+	f.Close()
+
+	d, err := Diagnose("diagnose.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.OpenError != nil || d.Info.Frames != 100 {
+		t.Errorf("couldn't open file %v %v", d.OpenError, d.Info)
+	}
+	if d.Problems != TrailingGarbage {
+		t.Errorf("expected trailing garbage, got %v", d.Problems)
+	}
+	var data *LogEntry
+	for n := range d.Log {
+		if d.Log[n].Chunk == "data" {
+			data = &d.Log[n]
+		}
+	}
+	if data == nil || data.Length != 200 || data.Offset <= 12 {
+		t.Errorf("data chunk not found in log: %+v", data)
+	}
+	if !strings.Contains(d.String(), "trailing garbage") {
+		t.Errorf("report doesn't mention the problem:\n%s", d)
+	}
+}
@@ -0,0 +1,419 @@
+package sndfile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Loudness is the EBU R128 measurement of a programme. Loudnesses are in LUFS and are -Inf for silence.
+type Loudness struct {
+	Integrated   float64 // gated loudness of the whole programme
+	Range        float64 // loudness range (LRA) in LU, as EBU Tech 3342 defines it
+	TruePeak     float64 // highest true peak of any channel, in dBTP
+	MaxMomentary float64 // highest loudness over 400 ms
+	MaxShortTerm float64 // highest loudness over 3 s
+}
+
+// The K-weighting filter of ITU-R BS.1770, designed for any sample rate: a high shelf modelling the head, and a high pass.
+type kWeighting struct {
+	b [2][3]float64
+	a [2][2]float64
+}
+
+func newKWeighting(rate float64) (k kWeighting) {
+	f0, g, q := 1681.974450955533, 3.999843853973347, 0.7071752369554196
+	kk := math.Tan(math.Pi * f0 / rate)
+	vh := math.Pow(10, g/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + kk/q + kk*kk
+	k.b[0] = [3]float64{(vh + vb*kk/q + kk*kk) / a0, 2 * (kk*kk - vh) / a0, (vh - vb*kk/q + kk*kk) / a0}
+	k.a[0] = [2]float64{2 * (kk*kk - 1) / a0, (1 - kk/q + kk*kk) / a0}
+
+	f0, q = 38.13547087602444, 0.5003270373238773
+	kk = math.Tan(math.Pi * f0 / rate)
+	a0 = 1 + kk/q + kk*kk
+	k.b[1] = [3]float64{1, -2, 1}
+	k.a[1] = [2]float64{2 * (kk*kk - 1) / a0, (1 - kk/q + kk*kk) / a0}
+	return
+}
+
+// BS.1770 weights the surround channels at ±60° to ±120° by +1.5 dB and leaves out the LFE.
+const surroundWeight = 1.41
+
+// loudnessWeights returns the weight of each channel of a layout. In 5.x the rear speakers are the surrounds; in 7.x it's the side speakers, and the rear ones, further back, have no extra weight.
+func loudnessWeights(channelMap []int32) []float64 {
+	sides := false
+	for _, p := range channelMap {
+		if p == ChannelMapSideLeft || p == ChannelMapSideRight {
+			sides = true
+		}
+	}
+	w := make([]float64, len(channelMap))
+	for c, p := range channelMap {
+		switch p {
+		case ChannelMapLfe:
+			w[c] = 0
+		case ChannelMapSideLeft, ChannelMapSideRight:
+			w[c] = surroundWeight
+		case ChannelMapRearLeft, ChannelMapRearRight:
+			if sides {
+				w[c] = 1
+			} else {
+				w[c] = surroundWeight
+			}
+		default:
+			w[c] = 1
+		}
+	}
+	return w
+}
+
+// loudnessOf converts a weighted mean square to LUFS.
+func loudnessOf(energy float64) float64 {
+	return -0.691 + 10*math.Log10(energy)
+}
+
+func energyOf(lufs float64) float64 {
+	return math.Pow(10, (lufs+0.691)/10)
+}
+
+const (
+	momentaryBlocks = 4  // 100 ms sub-blocks in the momentary window
+	shortTermBlocks = 30 // and in the short-term window
+)
+
+// A LoudnessMeter measures loudness as EBU R128 and ITU-R BS.1770-4 define it, over audio added to it a block at a time, so that it can follow a file as it is written. It keeps a value for every 100 ms of audio, so that the integrated loudness and range can be gated over the whole programme.
+type LoudnessMeter struct {
+	samplerate int
+	channels   int
+	weights    []float64
+	filter     kWeighting
+	state      [][2][4]float64          // per channel and stage: x[n-1], x[n-2], y[n-1], y[n-2]
+	subLen     int                      // frames in a 100 ms sub-block
+	subN       int                      // frames in the current sub-block so far
+	sum        []float64                // per channel sum of squares in the current sub-block
+	recent     [shortTermBlocks]float64 // weighted sums of the latest sub-blocks
+	subs       int                      // sub-blocks completed
+	blocks     []float64                // momentary energies every 100 ms, for gating the integrated loudness
+	shorts     []float64                // short-term energies every 100 ms, for the loudness range
+	maxM       float64
+	maxS       float64
+	peak       *truePeakMeter
+	buf        []float64
+}
+
+// NewLoudnessMeter returns a meter for audio at the given sample rate and with channels in the layout channelMap, or if it is nil the DefaultChannelMap for the number of channels, or failing that all channels weighted equally.
+func NewLoudnessMeter(samplerate, channels int, channelMap []int32) (*LoudnessMeter, error) {
+	if samplerate <= 0 || channels <= 0 {
+		return nil, errors.New("NewLoudnessMeter: bad sample rate or channel count")
+	}
+	if channelMap == nil {
+		channelMap = DefaultChannelMap(channels)
+	}
+	var weights []float64
+	if channelMap == nil {
+		weights = make([]float64, channels)
+		for c := range weights {
+			weights[c] = 1
+		}
+	} else if len(channelMap) != channels {
+		return nil, fmt.Errorf("NewLoudnessMeter: channel map has %d channels, expected %d", len(channelMap), channels)
+	} else {
+		weights = loudnessWeights(channelMap)
+	}
+	return &LoudnessMeter{
+		samplerate: samplerate,
+		channels:   channels,
+		weights:    weights,
+		filter:     newKWeighting(float64(samplerate)),
+		state:      make([][2][4]float64, channels),
+		subLen:     (samplerate + 5) / 10,
+		sum:        make([]float64, channels),
+		peak:       newTruePeakMeter(channels),
+	}, nil
+}
+
+// AddFrames adds interleaved frames normalised to [-1.0, 1.0]. A partial frame at the end is ignored.
+func (m *LoudnessMeter) AddFrames(frames []float64) {
+	n := len(frames) / m.channels
+	frames = frames[:n*m.channels]
+	m.peak.add(frames)
+	for i := 0; i < len(frames); i += m.channels {
+		for c := 0; c < m.channels; c++ {
+			if m.weights[c] == 0 {
+				continue
+			}
+			y := frames[i+c]
+			for s := range m.filter.b {
+				st := &m.state[c][s]
+				b, a := &m.filter.b[s], &m.filter.a[s]
+				x := y
+				y = b[0]*x + b[1]*st[0] + b[2]*st[1] - a[0]*st[2] - a[1]*st[3]
+				st[1], st[0] = st[0], x
+				st[3], st[2] = st[2], y
+			}
+			m.sum[c] += y * y
+		}
+		if m.subN++; m.subN == m.subLen {
+			m.endSubBlock()
+		}
+	}
+}
+
+// addItems adds samples of any of the types WriteItems takes, multiplied by scale to bring full scale to ±1.
+func (m *LoudnessMeter) addItems(in interface{}, items int, scale float64) {
+	if cap(m.buf) < items {
+		m.buf = make([]float64, items)
+	}
+	b := m.buf[:items]
+	switch s := in.(type) {
+	case []float64:
+		for i := range b {
+			b[i] = s[i] * scale
+		}
+	case []float32:
+		for i := range b {
+			b[i] = float64(s[i]) * scale
+		}
+	case []int16:
+		for i := range b {
+			b[i] = float64(s[i]) * scale
+		}
+	case []int32:
+		for i := range b {
+			b[i] = float64(s[i]) * scale
+		}
+	case []int:
+		for i := range b {
+			b[i] = float64(s[i]) * scale
+		}
+	default:
+		return
+	}
+	m.AddFrames(b)
+}
+
+// meterScale returns what samples of in must be multiplied by to be the level libsndfile writes them at, as a fraction of full scale. Floats are taken as ±1 only with normalisation on; otherwise they are in the range of the file's integer samples. Integers written to a float file are stored as they are unless SetIntFloatScaleWrite was set.
+func (f *File) meterScale(in interface{}) float64 {
+	var bits uint
+	switch in.(type) {
+	case []int16:
+		bits = 16
+	case []int32, []int:
+		bits = 32
+	}
+	sub := f.Format.Format & SF_FORMAT_SUBMASK
+	if sub == SF_FORMAT_FLOAT || sub == SF_FORMAT_DOUBLE {
+		if bits == 0 {
+			return 1
+		}
+		// libsndfile has no way to ask for this setting other than changing it
+		scaled := f.SetIntFloatScaleWrite(true)
+		f.SetIntFloatScaleWrite(scaled)
+		if !scaled {
+			return 1
+		}
+		return 1 / float64(uint64(1)<<(bits-1))
+	}
+	if bits == 0 {
+		var norm bool
+		if _, ok := in.([]float32); ok {
+			norm = f.GetFloatNormalization()
+		} else {
+			norm = f.GetDoubleNormalization()
+		}
+		if norm {
+			return 1
+		}
+		switch sub {
+		case SF_FORMAT_PCM_S8, SF_FORMAT_PCM_U8:
+			bits = 8
+		case SF_FORMAT_PCM_24:
+			bits = 24
+		case SF_FORMAT_PCM_32:
+			bits = 32
+		default:
+			bits = 16
+		}
+	}
+	return 1 / float64(uint64(1)<<(bits-1))
+}
+
+func (m *LoudnessMeter) endSubBlock() {
+	var e float64
+	for c, s := range m.sum {
+		e += m.weights[c] * s
+		m.sum[c] = 0
+	}
+	m.recent[m.subs%shortTermBlocks] = e
+	m.subs++
+	m.subN = 0
+	if m.subs >= momentaryBlocks {
+		e := m.windowEnergy(momentaryBlocks)
+		m.blocks = append(m.blocks, e)
+		m.maxM = math.Max(m.maxM, e)
+	}
+	if m.subs >= shortTermBlocks {
+		e := m.windowEnergy(shortTermBlocks)
+		m.shorts = append(m.shorts, e)
+		m.maxS = math.Max(m.maxS, e)
+	}
+}
+
+// windowEnergy returns the weighted mean square over the latest n sub-blocks.
+func (m *LoudnessMeter) windowEnergy(n int) float64 {
+	var e float64
+	for i := 1; i <= n; i++ {
+		e += m.recent[(m.subs-i)%shortTermBlocks]
+	}
+	return e / float64(n*m.subLen)
+}
+
+// Momentary returns the loudness of the last 400 ms, as of the last complete 100 ms of audio.
+func (m *LoudnessMeter) Momentary() float64 {
+	if m.subs < momentaryBlocks {
+		return math.Inf(-1)
+	}
+	return loudnessOf(m.windowEnergy(momentaryBlocks))
+}
+
+// ShortTerm returns the loudness of the last 3 s, as of the last complete 100 ms of audio.
+func (m *LoudnessMeter) ShortTerm() float64 {
+	if m.subs < shortTermBlocks {
+		return math.Inf(-1)
+	}
+	return loudnessOf(m.windowEnergy(shortTermBlocks))
+}
+
+// gate returns the energies above the absolute gate of -70 LUFS and then above the relative gate, which is rel LU below their mean.
+func gate(energies []float64, rel float64) []float64 {
+	abs := energyOf(-70)
+	var kept []float64
+	var sum float64
+	for _, e := range energies {
+		if e > abs {
+			kept = append(kept, e)
+			sum += e
+		}
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	thresh := sum / float64(len(kept)) * math.Pow(10, -rel/10)
+	var out []float64
+	for _, e := range kept {
+		if e > thresh {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Integrated returns the gated loudness of all the audio so far, from the 400 ms blocks above -70 LUFS and then above 10 LU below their mean.
+func (m *LoudnessMeter) Integrated() float64 {
+	g := gate(m.blocks, 10)
+	if len(g) == 0 {
+		return math.Inf(-1)
+	}
+	var sum float64
+	for _, e := range g {
+		sum += e
+	}
+	return loudnessOf(sum / float64(len(g)))
+}
+
+// Range returns the loudness range of all the audio so far: the spread between the 10th and 95th percentiles of the short-term loudness, over the 3 s windows above -70 LUFS and then above 20 LU below their mean. It is 0 for programmes shorter than 3 s.
+func (m *LoudnessMeter) Range() float64 {
+	g := gate(m.shorts, 20)
+	if len(g) == 0 {
+		return 0
+	}
+	sort.Float64s(g)
+	pct := func(p float64) float64 {
+		return loudnessOf(g[int(math.Floor(float64(len(g)-1)*p+0.5))])
+	}
+	return pct(0.95) - pct(0.10)
+}
+
+// TruePeaks returns the true peak of each channel in dBTP, measured with 4 times oversampling as in BS.1770 Annex 2, and the frame nearest to each.
+func (m *LoudnessMeter) TruePeaks() (dbtp []float64, at []int64) {
+	return m.peak.dbtp()
+}
+
+// Result returns all the measurements of the audio so far.
+func (m *LoudnessMeter) Result() Loudness {
+	l := Loudness{
+		Integrated:   m.Integrated(),
+		Range:        m.Range(),
+		TruePeak:     math.Inf(-1),
+		MaxMomentary: math.Inf(-1),
+		MaxShortTerm: math.Inf(-1),
+	}
+	tp, _ := m.TruePeaks()
+	for _, p := range tp {
+		l.TruePeak = math.Max(l.TruePeak, p)
+	}
+	if len(m.blocks) > 0 {
+		l.MaxMomentary = loudnessOf(m.maxM)
+	}
+	if len(m.shorts) > 0 {
+		l.MaxShortTerm = loudnessOf(m.maxS)
+	}
+	return l
+}
+
+// SetLoudnessMeter makes every block passed to WriteItems and WriteFrames on a file open for writing go to m too, after dithering, so that the loudness of the file is known when it is closed. Pass nil to stop.
+func (f *File) SetLoudnessMeter(m *LoudnessMeter) error {
+	if m != nil && m.channels != int(f.Format.Channels) {
+		return fmt.Errorf("SetLoudnessMeter: meter has %d channels, file has %d", m.channels, f.Format.Channels)
+	}
+	if m != nil && m.samplerate != int(f.Format.Samplerate) {
+		return fmt.Errorf("SetLoudnessMeter: meter is for %d Hz, file is %d Hz", m.samplerate, f.Format.Samplerate)
+	}
+	f.meter = m
+	return nil
+}
+
+// Loudness measures the loudness of the whole file, which must be open for reading, using its channel map to weight the channels. The read position is restored afterwards. If ctx is cancelled the measurement stops with its error.
+func (f *File) Loudness(ctx context.Context) (l Loudness, err error) {
+	m, err := NewLoudnessMeter(int(f.Format.Samplerate), int(f.Format.Channels), fileChannelMap(f))
+	if err != nil {
+		return l, err
+	}
+	err = f.readAll(ctx, m.AddFrames)
+	return m.Result(), err
+}
+
+// readAll passes all the frames of f to fn as normalised float64 blocks, from the start, then puts the read position and normalisation back as they were.
+func (f *File) readAll(ctx context.Context, fn func([]float64)) (err error) {
+	defer f.SetDoubleNormalization(f.SetDoubleNormalization(true))
+	pos, err := f.Seek(0, Current)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Seek(0, Set); err != nil {
+		return err
+	}
+	defer func() {
+		if _, serr := f.Seek(pos, Set); err == nil {
+			err = serr
+		}
+	}()
+	buf := make([]float64, 4096*int(f.Format.Channels))
+	for {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		n, err := f.ReadFrames(buf)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+		fn(buf[:int(n)*int(f.Format.Channels)])
+	}
+}
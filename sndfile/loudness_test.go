@@ -0,0 +1,169 @@
+package sndfile
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+// tone returns frames of a 1 kHz sine at 48 kHz with a peak of level dBFS in each of the channels given, and silence in the others.
+func tone(frames, channels int, level float64, in ...int) []float64 {
+	a := math.Pow(10, level/20)
+	out := make([]float64, frames*channels)
+	for n := 0; n < frames; n++ {
+		s := a * math.Sin(2*math.Pi*1000*float64(n)/48000)
+		for _, c := range in {
+			out[n*channels+c] = s
+		}
+	}
+	return out
+}
+
+func TestLoudnessMeter(t *testing.T) {
+	// EBU Tech 3341 case 1: a stereo sine at -23 dBFS reads -23 LUFS
+	m, err := NewLoudnessMeter(48000, 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.AddFrames(tone(20*48000, 2, -23, 0, 1))
+	l := m.Result()
+	if math.Abs(l.Integrated+23) > 0.1 || math.Abs(l.MaxMomentary+23) > 0.1 || math.Abs(l.MaxShortTerm+23) > 0.1 {
+		t.Errorf("-23 dBFS stereo sine measured %+v", l)
+	}
+	if math.Abs(m.Momentary()+23) > 0.1 || math.Abs(m.ShortTerm()+23) > 0.1 {
+		t.Errorf("momentary %g and short-term %g loudness, expected -23", m.Momentary(), m.ShortTerm())
+	}
+	if math.Abs(l.TruePeak+23) > 0.1 {
+		t.Errorf("true peak %g, expected -23 dBTP", l.TruePeak)
+	}
+	if l.Range > 0.1 {
+		t.Errorf("steady tone has loudness range %g", l.Range)
+	}
+
+	// EBU Tech 3342 case 1: 20 s at -20 dBFS then 20 s at -30 dBFS has a range of 10 LU
+	m, _ = NewLoudnessMeter(48000, 2, nil)
+	m.AddFrames(tone(20*48000, 2, -20, 0, 1))
+	m.AddFrames(tone(20*48000, 2, -30, 0, 1))
+	if r := m.Range(); math.Abs(r-10) > 1 {
+		t.Errorf("loudness range %g, expected 10", r)
+	}
+
+	// in 5.1 the LFE is left out and the surrounds count 1.5 dB more than the fronts
+	measure := func(c int) float64 {
+		m, _ := NewLoudnessMeter(48000, 6, nil)
+		m.AddFrames(tone(5*48000, 6, -20, c))
+		return m.Integrated()
+	}
+	front, lfe, surround := measure(0), measure(3), measure(4)
+	if !math.IsInf(lfe, -1) {
+		t.Errorf("LFE only measured %g LUFS", lfe)
+	}
+	if d := surround - front; math.Abs(d-1.5) > 0.05 {
+		t.Errorf("surround measured %g LU above front, expected 1.5", d)
+	}
+
+	m, _ = NewLoudnessMeter(48000, 2, nil)
+	m.AddFrames(make([]float64, 2*48000))
+	if l := m.Result(); !math.IsInf(l.Integrated, -1) || !math.IsInf(l.TruePeak, -1) {
+		t.Errorf("silence measured %+v", l)
+	}
+	if _, err = NewLoudnessMeter(48000, 3, []int32{ChannelMapLeft}); err == nil {
+		t.Error("expected an error for a channel map of the wrong length")
+	}
+}
+
+func TestFileLoudness(t *testing.T) {
+	// measure while writing, then again by reading the file back
+	i := Info{Samplerate: 48000, Channels: 2, Format: SF_FORMAT_WAV | SF_FORMAT_PCM_24}
+	f, err := Open("loudness.wav", Write, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, _ := NewLoudnessMeter(44100, 2, nil); f.SetLoudnessMeter(m) == nil {
+		t.Error("expected an error for a meter at another sample rate")
+	}
+	m, _ := NewLoudnessMeter(48000, 2, nil)
+	if err = f.SetLoudnessMeter(m); err != nil {
+		t.Fatal(err)
+	}
+	a := tone(10*48000, 2, -18, 0, 1)
+	for n := 0; n < len(a); n += 2 * 1000 {
+		f.WriteFrames(a[n : n+2*1000])
+	}
+	f.Close()
+	written := m.Result()
+
+	f, err = Open("loudness.wav", Read, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.Seek(100, Set)
+	read, err := f.Loudness(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos, _ := f.Seek(0, Current); pos != 100 {
+		t.Errorf("read position left at %d, expected 100", pos)
+	}
+	if math.Abs(read.Integrated+18) > 0.1 || math.Abs(read.Integrated-written.Integrated) > 0.01 {
+		t.Errorf("measured %g LUFS reading and %g writing, expected -18", read.Integrated, written.Integrated)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = f.Loudness(ctx); err != context.Canceled {
+		t.Errorf("expected cancellation, got %v", err)
+	}
+}
+
+func TestFileLoudnessScale(t *testing.T) {
+	// floats in the range of the file's samples, and ints a float file stores unscaled, must be metered as libsndfile stores them
+	a := tone(3*48000, 1, -18, 0)
+	cases := []struct {
+		format Format
+		write  func(f *File) error
+	}{
+		{SF_FORMAT_PCM_24, func(f *File) error {
+			f.SetDoubleNormalization(false)
+			b := make([]float64, len(a))
+			for i, s := range a {
+				b[i] = s * (1 << 23)
+			}
+			_, err := f.WriteFrames(b)
+			return err
+		}},
+		{SF_FORMAT_FLOAT, func(f *File) error {
+			b := make([]int16, len(a))
+			for i, s := range a {
+				b[i] = int16(s * (1 << 15))
+			}
+			_, err := f.WriteFrames(b)
+			return err
+		}},
+	}
+	for _, c := range cases {
+		i := Info{Samplerate: 48000, Channels: 1, Format: SF_FORMAT_WAV | c.format}
+		f, err := Open("loudness.wav", Write, &i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, _ := NewLoudnessMeter(48000, 1, nil)
+		f.SetLoudnessMeter(m)
+		if err = c.write(f); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		if f, err = Open("loudness.wav", Read, &i); err != nil {
+			t.Fatal(err)
+		}
+		read, err := f.Loudness(context.Background())
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if written := m.Result().Integrated; math.Abs(read.Integrated-written) > 0.01 {
+			t.Errorf("format %#x: metered %g LUFS writing and %g reading", c.format, written, read.Integrated)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package sndfile
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Metadata returns all the strings set in the file, keyed by string id. The map is empty if the file has none.
+func (f *File) Metadata() map[StringType]string {
+	m := make(map[StringType]string)
+	for _, t := range stringTypes {
+		if s := f.GetString(t); s != "" {
+			m[t] = s
+		}
+	}
+	return m
+}
+
+// SetMetadata sets every string in m on the file. All strings are attempted; the returned error names the ones libsndfile refused.
+func (f *File) SetMetadata(m map[StringType]string) error {
+	var failed []string
+	for _, t := range sortedStringTypes(m) {
+		if err := f.SetString(m[t], t); err != nil {
+			failed = append(failed, t.String())
+		}
+	}
+	if failed != nil {
+		return errors.New("SetMetadata: couldn't set " + strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func sortedStringTypes(m map[StringType]string) []StringType {
+	ts := make([]StringType, 0, len(m))
+	for t := range m {
+		ts = append(ts, t)
+	}
+	sort.Slice(ts, func(i, j int) bool { return ts[i] < ts[j] })
+	return ts
+}
+
+// CopyPolicy selects which kinds of metadata CopyMetadata transfers. The values may be ORed together.
+type CopyPolicy int
+
+const (
+	CopyStrings CopyPolicy = 1 << iota
+	CopyBroadcast
+	CopyCues
+	CopyInstrument
+	CopyChannelMap
+
+	CopyAll = CopyStrings | CopyBroadcast | CopyCues | CopyInstrument | CopyChannelMap
+)
+
+var copyPolicyNames = []string{"strings", "broadcast", "cues", "instrument", "channelmap"}
+
+func (p CopyPolicy) String() string {
+	var s []string
+	for i, n := range copyPolicyNames {
+		if p&(1<<uint(i)) != 0 {
+			s = append(s, n)
+		}
+	}
+	if s == nil {
+		return "none"
+	}
+	return strings.Join(s, "|")
+}
+
+// formatSupport is what trial writes found libsndfile keeps in a file of some format.
+type formatSupport struct {
+	kinds   CopyPolicy
+	strings map[StringType]bool
+}
+
+// supportKey is what a trial write depends on: some formats only take certain channel counts or sample rates.
+type supportKey struct {
+	format               Format
+	channels, samplerate int32
+}
+
+// metadataSupport caches a formatSupport for each format, channel count and sample rate asked about.
+var metadataSupport sync.Map
+
+// MetadataSupport returns the kinds of metadata libsndfile writes to a file with the format, channel count and sample rate of info. It is found once for each of those by writing a short file per kind with only that kind set and reading back what reached the disk. If libsndfile can't write such a file at all, nothing is supported and the answer isn't remembered.
+func MetadataSupport(info Info) CopyPolicy {
+	return supportFor(info).kinds
+}
+
+// stringSupported reports whether a file like info keeps strings of type t. Not every container has a slot for every string.
+func stringSupported(info Info, t StringType) bool {
+	return supportFor(info).strings[t]
+}
+
+func supportFor(info Info) formatSupport {
+	key := supportKey{info.Format, info.Channels, info.Samplerate}
+	if s, ok := metadataSupport.Load(key); ok {
+		return s.(formatSupport)
+	}
+	s, ok := probeSupport(key)
+	if ok {
+		metadataSupport.Store(key, s)
+	}
+	return s
+}
+
+// probeSupport writes one temporary file per kind of metadata and reports which kinds libsndfile kept. ok is false if a trial file couldn't be written.
+func probeSupport(key supportKey) (s formatSupport, ok bool) {
+	s.strings = make(map[StringType]bool)
+	cm := DefaultChannelMap(int(key.channels))
+	for _, kind := range []CopyPolicy{CopyStrings, CopyBroadcast, CopyCues, CopyInstrument, CopyChannelMap} {
+		if kind == CopyChannelMap && cm == nil {
+			continue
+		}
+		m, err := trialWrite(key, func(f *File) {
+			switch kind {
+			case CopyStrings:
+				for _, t := range stringTypes {
+					f.SetString(t.String(), t)
+				}
+			case CopyBroadcast:
+				f.SetBroadcastInfo(&BroadcastInfo{Description: "probe"})
+			case CopyCues:
+				f.SetCues([]CuePoint{{Index: 1, Position: 10, FccChunk: 0x61746164, SampleOffset: 10}})
+			case CopyInstrument:
+				f.SetInstrument(&Instrument{Gain: 1, Basenote: 60, Velocity: [2]int8{1, 127}, Key: [2]int8{0, 127}})
+			case CopyChannelMap:
+				f.SetChannelMapInfo(cm)
+			}
+		})
+		if err != nil {
+			return s, false
+		}
+		switch kind {
+		case CopyStrings:
+			for _, t := range stringTypes {
+				// libsndfile adds its own name to the software string
+				if strings.HasPrefix(m.Strings[t], t.String()) {
+					s.strings[t] = true
+					s.kinds |= CopyStrings
+				}
+			}
+		case CopyBroadcast:
+			if m.Broadcast != nil && m.Broadcast.Description == "probe" {
+				s.kinds |= CopyBroadcast
+			}
+		case CopyCues:
+			// AIFF markers only keep the sample offset
+			if len(m.Cues) == 1 && m.Cues[0].SampleOffset == 10 {
+				s.kinds |= CopyCues
+			}
+		case CopyInstrument:
+			if m.Instrument != nil && m.Instrument.Basenote == 60 {
+				s.kinds |= CopyInstrument
+			}
+		case CopyChannelMap:
+			if reflect.DeepEqual(m.ChannelMap, cm) {
+				s.kinds |= CopyChannelMap
+			}
+		}
+	}
+	return s, true
+}
+
+// trialWrite writes a short temporary file as key describes, with whatever set adds before the audio, and probes what ended up in it.
+func trialWrite(key supportKey, set func(*File)) (m *Metadata, err error) {
+	tmp, err := ioutil.TempFile("", "sndfile-support")
+	if err != nil {
+		return
+	}
+	name := tmp.Name()
+	tmp.Close()
+	defer os.Remove(name)
+
+	i := Info{Samplerate: key.samplerate, Channels: key.channels, Format: key.format}
+	f, err := Open(name, Write, &i)
+	if err != nil {
+		return
+	}
+	set(f)
+	f.WriteFrames(make([]int16, int(key.channels)*100))
+	if err = f.Close(); err != nil {
+		return
+	}
+	return Probe(name)
+}
+
+// MetadataReport describes what CopyMetadata could not carry over.
+type MetadataReport struct {
+	Dropped        CopyPolicy   // kinds of metadata present in the source that the destination didn't get
+	DroppedStrings []StringType // individual strings the destination format has no slot for
+}
+
+// CopyMetadata copies the metadata selected by policy from src to dst: strings, broadcast info, cues, instrument and channel map. dst must be open for writing and, since libsndfile only accepts most of these before the header is written, no audio should have been written to it yet.
+
+// Metadata that the destination format can't hold is skipped and listed in the report rather than treated as an error. err is non-nil if dst should have been able to take something but libsndfile refused it.
+func CopyMetadata(dst, src *File, policy CopyPolicy) (report MetadataReport, err error) {
+	support := MetadataSupport(dst.Format)
+	drop := func(p CopyPolicy, e error) {
+		report.Dropped |= p
+		if err == nil {
+			err = e
+		}
+	}
+
+	if policy&CopyStrings != 0 {
+		m := src.Metadata()
+		for _, t := range sortedStringTypes(m) {
+			if support&CopyStrings == 0 || !stringSupported(dst.Format, t) {
+				report.DroppedStrings = append(report.DroppedStrings, t)
+				report.Dropped |= CopyStrings
+				continue
+			}
+			if e := dst.SetString(m[t], t); e != nil {
+				report.DroppedStrings = append(report.DroppedStrings, t)
+				drop(CopyStrings, e)
+			}
+		}
+	}
+
+	if policy&CopyBroadcast != 0 {
+		if bi, ok := src.GetBroadcastInfo(); ok {
+			if support&CopyBroadcast == 0 {
+				report.Dropped |= CopyBroadcast
+			} else if e := dst.SetBroadcastInfo(bi); e != nil {
+				drop(CopyBroadcast, e)
+			}
+		}
+	}
+
+	if policy&CopyCues != 0 {
+		if cues, ok := src.GetCues(); ok {
+			if support&CopyCues == 0 {
+				report.Dropped |= CopyCues
+			} else if e := dst.SetCues(cues); e != nil {
+				drop(CopyCues, e)
+			}
+		}
+	}
+
+	if policy&CopyInstrument != 0 {
+		if inst := src.GetInstrument(); inst != nil {
+			if support&CopyInstrument == 0 {
+				report.Dropped |= CopyInstrument
+			} else if e := dst.SetInstrument(inst); e != nil {
+				drop(CopyInstrument, e)
+			}
+		}
+	}
+
+	if policy&CopyChannelMap != 0 && src.Format.Channels > 0 {
+		if cm, e := src.GetChannelMapInfo(); e == nil {
+			if support&CopyChannelMap == 0 || dst.Format.Channels != src.Format.Channels {
+				report.Dropped |= CopyChannelMap
+			} else if e := dst.SetChannelMapInfo(cm); e != nil {
+				drop(CopyChannelMap, e)
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,163 @@
+package sndfile
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeMetadataSource(t *testing.T, name string) {
+	var i Info
+	i.Format = SF_FORMAT_WAVEX | SF_FORMAT_PCM_16
+	i.Channels = 2
+	i.Samplerate = 8000
+	f, err := Open(name, Write, &i)
+	if err != nil {
+		t.Fatal("couldn't open file to write", err)
+	}
+	err = f.SetMetadata(map[StringType]string{Title: "a title", Artist: "an artist", Album: "an album"})
+	if err != nil {
+		t.Error("SetMetadata failed", err)
+	}
+	bi := &BroadcastInfo{Description: "desc", Originator: "gosndfile", Time_reference_low: 1234, Coding_history: []int8{'A', '=', 'P', 'C', 'M'}}
+	if err = f.SetBroadcastInfo(bi); err != nil {
+		t.Error("SetBroadcastInfo failed", err)
+	}
+	if err = f.SetCues([]CuePoint{{Index: 1, Position: 100, SampleOffset: 100}, {Index: 2, Position: 200, SampleOffset: 200}}); err != nil {
+		t.Error("SetCues failed", err)
+	}
+	inst := &Instrument{Gain: 1, Basenote: 60, Velocity: [2]int8{1, 127}, Key: [2]int8{0, 127}}
+	if err = f.SetInstrument(inst); err != nil {
+		t.Error("SetInstrument failed", err)
+	}
+	if err = f.SetChannelMapInfo([]int32{ChannelMapLeft, ChannelMapRight}); err != nil {
+		t.Error("SetChannelMapInfo failed", err)
+	}
+	f.WriteFrames(make([]int16, 2*1000))
+	f.Close()
+}
+
+func TestCopyMetadata(t *testing.T) {
+	writeMetadataSource(t, "metadatasrc.wav")
+	var si Info
+	src, err := Open("metadatasrc.wav", Read, &si)
+	if err != nil {
+		t.Fatal("couldn't open source", err)
+	}
+	defer src.Close()
+	want := map[StringType]string{Title: "a title", Artist: "an artist", Album: "an album"}
+	if m := src.Metadata(); !reflect.DeepEqual(m, want) {
+		t.Errorf("Metadata returned %v, expected %v", m, want)
+	}
+
+	// same container, everything should come across
+	di := si
+	dst, err := Open("metadatadst.wav", Write, &di)
+	if err != nil {
+		t.Fatal("couldn't open destination", err)
+	}
+	report, err := CopyMetadata(dst, src, CopyAll)
+	if err != nil || report.Dropped != 0 {
+		t.Errorf("unexpected drops copying wav to wav: %v %v", report.Dropped, err)
+	}
+	dst.WriteFrames(make([]int16, 2*1000))
+	dst.Close()
+
+	sm, err := Probe("metadatasrc.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dm, err := Probe("metadatadst.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sm.Strings, dm.Strings) {
+		t.Errorf("strings differ %v %v", sm.Strings, dm.Strings)
+	}
+	if dm.Broadcast == nil {
+		t.Fatal("broadcast info didn't survive")
+	}
+	if dm.Broadcast.Description != "desc" || dm.Broadcast.Originator != "gosndfile" || dm.Broadcast.Time_reference_low != 1234 {
+		t.Errorf("broadcast info differs %v %v", sm.Broadcast, dm.Broadcast)
+	}
+	// libsndfile appends its own line to the coding history every time it writes one
+	if !strings.HasPrefix(string(int8sToBytes(dm.Broadcast.Coding_history)), "A=PCM") {
+		t.Errorf("coding history didn't survive %q", int8sToBytes(dm.Broadcast.Coding_history))
+	}
+	if !reflect.DeepEqual(sm.Cues, dm.Cues) || len(dm.Cues) != 2 {
+		t.Errorf("cues differ %v %v", sm.Cues, dm.Cues)
+	}
+	if !reflect.DeepEqual(sm.Instrument, dm.Instrument) || dm.Instrument == nil {
+		t.Errorf("instrument differs %v %v", sm.Instrument, dm.Instrument)
+	}
+	if !reflect.DeepEqual(sm.ChannelMap, dm.ChannelMap) {
+		t.Errorf("channel map differs %v %v", sm.ChannelMap, dm.ChannelMap)
+	}
+
+	// AIFF has no bext chunk and no slot for an album string, and what else it keeps depends on the libsndfile version, so the report is checked against what reached the disk
+	di = Info{Samplerate: 8000, Channels: 2, Format: SF_FORMAT_AIFF | SF_FORMAT_PCM_16}
+	dst, err = Open("metadatadst.aiff", Write, &di)
+	if err != nil {
+		t.Fatal("couldn't open destination", err)
+	}
+	report, err = CopyMetadata(dst, src, CopyAll)
+	if err != nil {
+		t.Error("CopyMetadata failed", err)
+	}
+	dst.WriteFrames(make([]int16, 2*1000))
+	dst.Close()
+	am, err := Probe("metadatadst.aiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if am.Strings[Title] != "a title" || !reflect.DeepEqual(am.ChannelMap, []int32{ChannelMapLeft, ChannelMapRight}) {
+		t.Errorf("aiff lost metadata it should have kept %v", am)
+	}
+	var lost CopyPolicy
+	var lostStrings []StringType
+	for _, st := range sortedStringTypes(sm.Strings) {
+		if am.Strings[st] != sm.Strings[st] {
+			lostStrings = append(lostStrings, st)
+			lost |= CopyStrings
+		}
+	}
+	if am.Broadcast == nil {
+		lost |= CopyBroadcast
+	}
+	// AIFF markers keep only the sample offset
+	if len(am.Cues) != len(sm.Cues) || len(am.Cues) == 0 || am.Cues[0].SampleOffset != sm.Cues[0].SampleOffset || am.Cues[1].SampleOffset != sm.Cues[1].SampleOffset {
+		lost |= CopyCues
+	}
+	if am.Instrument == nil {
+		lost |= CopyInstrument
+	}
+	if report.Dropped != lost || !reflect.DeepEqual(report.DroppedStrings, lostStrings) {
+		t.Errorf("report dropped %v %v, the file lost %v %v", report.Dropped, report.DroppedStrings, lost, lostStrings)
+	}
+	if lost&CopyBroadcast == 0 || lost&CopyCues != 0 || len(lostStrings) != 1 || lostStrings[0] != Album {
+		t.Errorf("expected aiff to lose only broadcast info and album, lost %v %v", lost, lostStrings)
+	}
+	if s := MetadataSupport(di); s&^CopyStrings != CopyAll&^CopyStrings&^lost || s&CopyStrings == 0 {
+		t.Errorf("MetadataSupport gave %v for aiff, the file lost %v", MetadataSupport(di), lost)
+	}
+}
+
+func TestMetadataSupportChannels(t *testing.T) {
+	// GSM 6.10 in WAV is mono only, so a stereo trial file can't be written
+	gsm := Info{Samplerate: 8000, Channels: 1, Format: SF_FORMAT_WAV | SF_FORMAT_GSM610}
+	if s := MetadataSupport(gsm); s&CopyStrings == 0 || s&CopyCues == 0 {
+		t.Errorf("MetadataSupport gave %v for mono gsm wav", s)
+	}
+	gsm.Channels = 2
+	if s := MetadataSupport(gsm); s != 0 {
+		t.Errorf("MetadataSupport gave %v for stereo gsm wav", s)
+	}
+}
+
+func int8sToBytes(in []int8) []byte {
+	b := make([]byte, len(in))
+	for i, c := range in {
+		b[i] = byte(c)
+	}
+	return b
+}
@@ -0,0 +1,164 @@
+package sndfile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// A Track is one input to a Mixer.
+type Track struct {
+	File   *File         // open for reading; it is read from the start, and tracks can't share one, since each needs its own read position
+	Offset time.Duration // where the track starts in the mix
+	Gain   float64       // in dB
+	Pan    float64       // from -1 for hard left to 1 for hard right, with a constant power law; only for stereo mixes
+	Matrix RemixMatrix   // if not nil, routes the track's channels into the mix in place of converting its layout, and Pan must be 0
+}
+
+// MixReport tells what Mix wrote.
+type MixReport struct {
+	Frames  int64
+	Limited bool // the limiter had to bring the level down somewhere
+}
+
+// A Mixer sums tracks into one file. Each track is converted to the mix's sample rate and, unless it has a routing matrix, to its layout as Convert would, then scaled by its gain and pan and added in at its offset. The mix runs until the last track ends, with silence wherever no track is playing.
+type Mixer struct {
+	Samplerate int
+	Channels   int
+	Limit      bool    // limit the mix so its true peak stays below Ceiling
+	Ceiling    float64 // in dBTP, for Limit
+
+	tracks []Track
+}
+
+// NewMixer returns a Mixer with no tracks, mixing at samplerate into the DefaultChannelMap layout for channels.
+func NewMixer(samplerate, channels int) *Mixer {
+	return &Mixer{Samplerate: samplerate, Channels: channels}
+}
+
+// AddTrack adds t to the mix.
+func (m *Mixer) AddTrack(t Track) error {
+	if t.File == nil {
+		return errors.New("AddTrack: no file")
+	}
+	if t.Pan < -1 || t.Pan > 1 {
+		return fmt.Errorf("AddTrack: pan %g outside -1 to 1", t.Pan)
+	}
+	if t.Pan != 0 && (t.Matrix != nil || m.Channels != 2) {
+		return errors.New("AddTrack: pan needs a stereo mix and no matrix")
+	}
+	if t.Offset < 0 {
+		return errors.New("AddTrack: negative offset")
+	}
+	for k, o := range m.tracks {
+		if o.File == t.File {
+			return fmt.Errorf("AddTrack: file already used by track %d; open it again for another track", k)
+		}
+	}
+	m.tracks = append(m.tracks, t)
+	return nil
+}
+
+// mixTrack is a track being read into the mix.
+type mixTrack struct {
+	read       func([]float64) (int64, error)
+	start, end int64
+	gains      []float64 // per mix channel
+}
+
+// Mix reads every track from its start and writes the mix to dst, which must be open for writing with the Mixer's sample rate and channel count. Summing is done in float64; integer formats are clipped rather than wrapped, so set Limit for a mix that may go over full scale. dst is not closed.
+func (m *Mixer) Mix(ctx context.Context, dst *File) (report MixReport, err error) {
+	if int(dst.Format.Samplerate) != m.Samplerate || int(dst.Format.Channels) != m.Channels {
+		return report, fmt.Errorf("Mix: destination is %d Hz with %d channels, mixer is %d Hz with %d", dst.Format.Samplerate, dst.Format.Channels, m.Samplerate, m.Channels)
+	}
+	if len(m.tracks) == 0 {
+		return report, errors.New("Mix: no tracks")
+	}
+	channels := m.Channels
+	to := DefaultChannelMap(channels)
+	var total int64
+	tracks := make([]mixTrack, len(m.tracks))
+	for k, t := range m.tracks {
+		if _, err = t.File.Seek(0, Set); err != nil {
+			return report, err
+		}
+		mt := &tracks[k]
+		var frames int64
+		if mt.read, frames, err = convertedReader(t.File, t.Matrix, to, channels, int32(m.Samplerate), ResampleMedium); err != nil {
+			return report, fmt.Errorf("Mix: track %d: %v", k, err)
+		}
+		mt.start = durationFrames(t.Offset, int32(m.Samplerate))
+		mt.end = mt.start + frames
+		total = max64(total, mt.end)
+		g := math.Pow(10, t.Gain/20)
+		mt.gains = make([]float64, channels)
+		for c := range mt.gains {
+			mt.gains[c] = g
+		}
+		if t.Pan != 0 {
+			a := (t.Pan + 1) * math.Pi / 4
+			mt.gains[0] *= math.Sqrt2 * math.Cos(a)
+			mt.gains[1] *= math.Sqrt2 * math.Sin(a)
+		}
+	}
+
+	dst.SetClipping(true)
+	var lim *limiter
+	if m.Limit {
+		lim = newLimiter(channels, m.Samplerate, math.Pow(10, m.Ceiling/20))
+	}
+	write := func(b []float64) error {
+		if len(b) == 0 {
+			return nil
+		}
+		n, err := dst.WriteFrames(b)
+		report.Frames += n
+		if err == nil && n != int64(len(b)/channels) {
+			err = errors.New("Mix: short write")
+		}
+		return err
+	}
+
+	const block = 4096
+	mix := make([]float64, block*channels)
+	buf := make([]float64, block*channels)
+	for pos := int64(0); pos < total; pos += block {
+		if err = ctx.Err(); err != nil {
+			return report, err
+		}
+		n := min64(block, total-pos)
+		b := mix[:n*int64(channels)]
+		for i := range b {
+			b[i] = 0
+		}
+		for _, t := range tracks {
+			from, until := max64(t.start, pos), min64(t.end, pos+n)
+			if from >= until {
+				continue
+			}
+			got, err := readFull(t.read, buf[:(until-from)*int64(channels)], channels)
+			if err != nil {
+				return report, err
+			}
+			out := b[(from-pos)*int64(channels):]
+			for i, s := range buf[:got*int64(channels)] {
+				out[i] += s * t.gains[i%channels]
+			}
+		}
+		if lim != nil {
+			b = lim.process(b)
+		}
+		if err = write(b); err != nil {
+			return report, err
+		}
+	}
+	if lim != nil {
+		if err = write(lim.finish()); err != nil {
+			return report, err
+		}
+		report.Limited = lim.reduced
+	}
+	return report, nil
+}
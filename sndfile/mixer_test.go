@@ -0,0 +1,116 @@
+package sndfile
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func constFrames(frames int, values ...float64) []float64 {
+	out := make([]float64, 0, frames*len(values))
+	for n := 0; n < frames; n++ {
+		out = append(out, values...)
+	}
+	return out
+}
+
+func TestMixer(t *testing.T) {
+	float := SF_FORMAT_WAV | SF_FORMAT_FLOAT
+	writeFloats(t, "mixa.wav", Info{Samplerate: 48000, Channels: 1, Format: float}, constFrames(1000, 0.5), nil)
+	writeFloats(t, "mixb.wav", Info{Samplerate: 24000, Channels: 2, Format: float}, constFrames(500, 0.25, -0.25), nil)
+	writeFloats(t, "mixc.wav", Info{Samplerate: 48000, Channels: 2, Format: float}, constFrames(100, 0.1, 0.2), nil)
+	var tracks []*File
+	for _, name := range []string{"mixa.wav", "mixb.wav", "mixc.wav"} {
+		var i Info
+		f, err := Open(name, Read, &i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		tracks = append(tracks, f)
+	}
+
+	// the mono track panned hard left, the stereo track at half the rate 6 dB down from 2000 frames in, and the last with its channels swapped
+	m := NewMixer(48000, 2)
+	for _, tr := range []Track{
+		{File: tracks[0], Pan: -1},
+		{File: tracks[1], Offset: 2000 * time.Second / 48000, Gain: -20 * math.Log10(2)},
+		{File: tracks[2], Matrix: RemixMatrix{{0, 1}, {1, 0}}},
+	} {
+		if err := m.AddTrack(tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	i := Info{Samplerate: 48000, Channels: 2, Format: float}
+	f, err := Open("mixdst.wav", Write, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := m.Mix(context.Background(), f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Frames != 3000 || r.Limited {
+		t.Errorf("unexpected report %+v", r)
+	}
+	if f, err = Open("mixdst.wav", Read, &i); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]float64, 2*i.Frames)
+	f.ReadFrames(b)
+	f.Close()
+	for _, c := range []struct {
+		frame       int
+		left, right float64
+	}{{50, 0.7, 0.1}, {500, 0.5, 0}, {1500, 0, 0}, {2500, 0.125, -0.125}} {
+		if l, r := b[2*c.frame], b[2*c.frame+1]; math.Abs(l-c.left) > 1e-3 || math.Abs(r-c.right) > 1e-3 {
+			t.Errorf("frame %d is %g, %g, expected %g, %g", c.frame, l, r, c.left, c.right)
+		}
+	}
+
+	// a track needs a file of its own
+	if err = m.AddTrack(Track{File: tracks[2]}); err == nil {
+		t.Error("expected an error adding a file twice")
+	}
+
+	// two loud tracks together make about 1.19 in each channel, over full scale, and the limiter holds them below the ceiling
+	var ci Info
+	again, err := Open("mixc.wav", Read, &ci)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer again.Close()
+	m = NewMixer(48000, 2)
+	m.Limit, m.Ceiling = true, -1
+	if err = m.AddTrack(Track{File: tracks[2], Gain: 12}); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.AddTrack(Track{File: again, Gain: 12, Matrix: RemixMatrix{{0, 1}, {1, 0}}}); err != nil {
+		t.Fatal(err)
+	}
+	if f, err = Open("mixdst.wav", Write, &i); err != nil {
+		t.Fatal(err)
+	}
+	r, err = m.Mix(context.Background(), f)
+	f.Close()
+	if err != nil || r.Frames != 100 || !r.Limited {
+		t.Fatalf("limited mix gave %+v, %v", r, err)
+	}
+	if f, err = Open("mixdst.wav", Read, &i); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// with the channels of one track swapped, both channels of the mix get the same from the two together
+	peaks, _ := f.CalcNormMaxAllChannels()
+	for c, p := range peaks {
+		if p > math.Pow(10, -1.0/20) || p < 0.5 || math.Abs(p-peaks[0]) > 1e-3 {
+			t.Errorf("limited mix peaks at %g in channel %d, expected both near the ceiling", p, c)
+		}
+	}
+
+	if err = m.AddTrack(Track{File: tracks[0], Pan: 2}); err == nil {
+		t.Error("expected an error for pan out of range")
+	}
+}
@@ -0,0 +1,158 @@
+package sndfile
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// A MultiReader reads an ordered list of files as one stream, so that a recording made in parts can be processed without gaps. Frame positions count from the start of the first file, and reads and seeks cross from one file to the next as if they were one.
+type MultiReader struct {
+	Format Info // the format shared by the files, with Frames the total over all of them
+
+	files  []*File
+	starts []int64 // the position of each file's first frame, and the total at the end
+	cur    int     // the file being read
+	pos    int64
+	owned  bool
+}
+
+// OpenMulti opens the files at names for reading as one stream. They must all have the same sample rate, channel count and format.
+func OpenMulti(names ...string) (*MultiReader, error) {
+	files := make([]*File, 0, len(names))
+	for _, name := range names {
+		var i Info
+		f, err := Open(name, Read, &i)
+		if err != nil {
+			for _, f := range files {
+				f.Close()
+			}
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	m, err := NewMultiReader(files...)
+	if err != nil {
+		for _, f := range files {
+			f.Close()
+		}
+		return nil, err
+	}
+	m.owned = true
+	return m, nil
+}
+
+// NewMultiReader returns a MultiReader over files, which must be open for reading and have the same sample rate, channel count and format. The MultiReader moves their read positions as it goes, and doesn't close them.
+func NewMultiReader(files ...*File) (*MultiReader, error) {
+	if len(files) == 0 {
+		return nil, errors.New("NewMultiReader: no files")
+	}
+	m := &MultiReader{files: files, starts: make([]int64, len(files)+1)}
+	m.Format = files[0].Format
+	for k, f := range files {
+		i := f.Format
+		if i.Samplerate != m.Format.Samplerate || i.Channels != m.Format.Channels || i.Format != m.Format.Format {
+			return nil, fmt.Errorf("NewMultiReader: file %d has format %d Hz, %d channels, %#x, expected %d Hz, %d channels, %#x", k, i.Samplerate, i.Channels, i.Format, m.Format.Samplerate, m.Format.Channels, m.Format.Format)
+		}
+		m.starts[k+1] = m.starts[k] + i.Frames
+	}
+	m.Format.Frames = m.starts[len(files)]
+	if _, err := files[0].Seek(0, Set); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ReadFrames fills out, which is a slice of one of the types File.ReadFrames accepts, with frames from the current position, moving on through the files as each one ends. It returns the number of frames read, which is only less than will fit in out at the end of the last file, and 0 with no error if out can't hold a whole frame. It is an error for a file to end before the number of frames its header gives.
+func (m *MultiReader) ReadFrames(out interface{}) (read int64, err error) {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Slice {
+		return 0, errors.New("MultiReader: ReadFrames needs a slice")
+	}
+	channels := int64(m.Format.Channels)
+	frames := int64(v.Len()) / channels
+	if frames < 1 {
+		return 0, nil
+	}
+	for read < frames && m.cur < len(m.files) {
+		n, err := m.files[m.cur].ReadFrames(v.Slice(int(read*channels), int(frames*channels)).Interface())
+		if err != nil {
+			return read, err
+		}
+		// the files' places in the stream come from their headers, so one that ends early would throw out every position after it
+		if n == 0 && m.pos < m.starts[m.cur+1] {
+			return read, fmt.Errorf("MultiReader: file %d ended after %d frames, its header gives %d", m.cur, m.pos-m.starts[m.cur], m.starts[m.cur+1]-m.starts[m.cur])
+		}
+		read += n
+		m.pos += n
+		if m.pos >= m.starts[m.cur+1] {
+			if err = m.enter(m.cur+1, 0); err != nil {
+				return read, err
+			}
+		}
+	}
+	return read, nil
+}
+
+// ReadItems is ReadFrames for a slice holding whole frames, and returns the number of items read.
+func (m *MultiReader) ReadItems(out interface{}) (read int64, err error) {
+	v := reflect.ValueOf(out)
+	if v.Kind() == reflect.Slice && v.Len()%int(m.Format.Channels) != 0 {
+		return 0, errors.New("MultiReader: ReadItems needs whole frames")
+	}
+	read, err = m.ReadFrames(out)
+	return read * int64(m.Format.Channels), err
+}
+
+// enter moves to frame offset of file k, or past the end of the stream if k is the number of files.
+func (m *MultiReader) enter(k int, offset int64) error {
+	m.cur = k
+	if k == len(m.files) {
+		return nil
+	}
+	_, err := m.files[k].Seek(offset, Set)
+	return err
+}
+
+// Seek moves the read position as File.Seek does, counting frames over the whole stream, and returns the new position.
+func (m *MultiReader) Seek(frames int64, w Whence) (offset int64, err error) {
+	switch w {
+	case Set:
+		offset = frames
+	case Current:
+		offset = m.pos + frames
+	case End:
+		offset = m.Format.Frames + frames
+	default:
+		return m.pos, errors.New("MultiReader: bad whence")
+	}
+	if offset < 0 || offset > m.Format.Frames {
+		return m.pos, fmt.Errorf("MultiReader: seek to %d outside %d frames", offset, m.Format.Frames)
+	}
+	// the last file whose start is at or before the offset, so that the end of one file is read as the start of the next
+	k := len(m.files) - 1
+	for m.starts[k] > offset {
+		k--
+	}
+	for k < len(m.files)-1 && m.starts[k+1] == offset {
+		k++
+	}
+	if err = m.enter(k, offset-m.starts[k]); err != nil {
+		return m.pos, err
+	}
+	m.pos = offset
+	return offset, nil
+}
+
+// Close closes the files if the MultiReader opened them.
+func (m *MultiReader) Close() (err error) {
+	if !m.owned {
+		return nil
+	}
+	for _, f := range m.files {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
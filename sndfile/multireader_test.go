@@ -0,0 +1,107 @@
+package sndfile
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMultiReader(t *testing.T) {
+	// stereo parts of 1000, 0, 2500 and 700 frames, one ramp across them all
+	lengths := []int{1000, 0, 2500, 700}
+	var all []int16
+	var names []string
+	for k, l := range lengths {
+		i := Info{Samplerate: 8000, Channels: 2, Format: SF_FORMAT_WAV | SF_FORMAT_PCM_16}
+		name := fmt.Sprintf("multi%d.wav", k)
+		f, err := Open(name, Write, &i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part := make([]int16, 2*l)
+		for j := range part {
+			part[j] = int16(len(all) + j)
+		}
+		if l > 0 {
+			f.WriteItems(part)
+		}
+		f.Close()
+		all = append(all, part...)
+		names = append(names, name)
+	}
+
+	m, err := OpenMulti(names...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if m.Format.Frames != 4200 || m.Format.Channels != 2 {
+		t.Fatalf("format %+v, expected 4200 stereo frames", m.Format)
+	}
+	var got []int16
+	b := make([]int16, 2*333)
+	for {
+		n, err := m.ReadFrames(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == 0 {
+			break
+		}
+		got = append(got, b[:2*n]...)
+	}
+	if len(got) != len(all) {
+		t.Fatalf("read %d samples, expected %d", len(got), len(all))
+	}
+	for j := range all {
+		if got[j] != all[j] {
+			t.Fatalf("sample %d is %d, expected %d", j, got[j], all[j])
+		}
+	}
+
+	for _, c := range []struct {
+		frames int64
+		w      Whence
+		pos    int64
+	}{{998, Set, 998}, {-5, End, 4195}, {-3200, Current, 995}, {1000, Set, 1000}, {3500, Set, 3500}, {0, Current, 3500}} {
+		pos, err := m.Seek(c.frames, c.w)
+		if err != nil || pos != c.pos {
+			t.Fatalf("seek by %d from %d went to %d, %v, expected %d", c.frames, c.w, pos, err, c.pos)
+		}
+		n, _ := m.ReadItems(b[:8])
+		if want := min64(8, 2*(4200-pos)); n != want || b[0] != all[2*pos] || b[n-1] != all[2*pos+n-1] {
+			t.Errorf("read %d items from %d starting %d, expected %d starting %d", n, pos, b[0], want, all[2*pos])
+		}
+		m.Seek(pos, Set)
+	}
+	if _, err = m.Seek(4201, Set); err == nil {
+		t.Error("expected an error seeking past the end")
+	}
+	if n, err := m.ReadFrames(b[:1]); n != 0 || err != nil {
+		t.Errorf("reading into less than a frame gave %d, %v", n, err)
+	}
+
+	// a file that gives fewer frames than its header says would put everything after it in the wrong place
+	var si Info
+	short, err := Open(names[0], Read, &si)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer short.Close()
+	short.Format.Frames += 10
+	sm, err := NewMultiReader(short)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for err == nil {
+		var n int64
+		if n, err = sm.ReadFrames(b); n == 0 && err == nil {
+			t.Fatal("reached the end of a file that ended short")
+		}
+	}
+
+	i := Info{Samplerate: 8000, Channels: 1, Format: SF_FORMAT_WAV | SF_FORMAT_PCM_16}
+	writeRamp(t, "multi1.wav", i, 100)
+	if _, err = OpenMulti(names...); err == nil {
+		t.Error("expected an error for files of different formats")
+	}
+}
@@ -0,0 +1,200 @@
+package sndfile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// TargetKind is what Normalize measures and brings to the target level.
+type TargetKind int
+
+const (
+	PeakDBFS TargetKind = iota // sample peak, in dBFS
+	LUFS                       // integrated loudness, as a LoudnessMeter measures it
+	TruePeak                   // true peak, in dBTP, as CalcTruePeakAllChannels measures it
+)
+
+func (k TargetKind) String() string {
+	switch k {
+	case PeakDBFS:
+		return "dBFS"
+	case LUFS:
+		return "LUFS"
+	case TruePeak:
+		return "dBTP"
+	}
+	return fmt.Sprintf("TargetKind(%d)", int(k))
+}
+
+// Target is the level Normalize brings a file to.
+type Target struct {
+	Kind    TargetKind
+	Level   float64 // in the units of Kind
+	Limit   bool    // limit the output so its true peak stays below Ceiling
+	Ceiling float64 // in dBTP, for Limit
+}
+
+// NormalizeReport tells what Normalize did.
+type NormalizeReport struct {
+	Measured float64 // level of the source, in the units of the target
+	Gain     float64 // in dB
+	Limited  bool    // the limiter had to bring the level down somewhere
+	Recorded string  // where the gain was noted: "bext", "comment", or empty if the format has room for neither
+}
+
+// Normalize writes a copy of the file at src to dst, in the same format, with its level changed to meet target. A first pass over src measures its level; the second applies the gain, through a limiter if target.Limit is set, with integer formats clipped rather than wrapped. Metadata is carried over as by CopyMetadata, and the gain is noted in the coding history of the broadcast extension if src has one and there is room, or else appended to the comment. For a broadcast extension, libsndfile then adds a line of its own.
+//
+// If normalizing fails or ctx is cancelled, the partly written dst is removed.
+func Normalize(ctx context.Context, src, dst string, target Target) (report NormalizeReport, err error) {
+	var si Info
+	in, err := Open(src, Read, &si)
+	if err != nil {
+		return report, err
+	}
+	defer in.Close()
+	channels := int(si.Channels)
+
+	switch target.Kind {
+	case PeakDBFS:
+		peak, err := in.CalcNormSignalMax()
+		if err != nil {
+			return report, err
+		}
+		report.Measured = 20 * math.Log10(peak)
+	case LUFS:
+		l, err := in.Loudness(ctx)
+		if err != nil {
+			return report, err
+		}
+		report.Measured = l.Integrated
+	case TruePeak:
+		tp, _, err := in.CalcTruePeakAllChannels(ctx)
+		if err != nil {
+			return report, err
+		}
+		report.Measured = math.Inf(-1)
+		for _, p := range tp {
+			report.Measured = math.Max(report.Measured, p)
+		}
+	default:
+		return report, fmt.Errorf("Normalize: unknown target %v", target.Kind)
+	}
+	if math.IsInf(report.Measured, -1) {
+		return report, errors.New("Normalize: source is silent")
+	}
+	report.Gain = target.Level - report.Measured
+	gain := math.Pow(10, report.Gain/20)
+
+	di := si
+	out, err := Open(dst, Write, &di)
+	if err != nil {
+		return report, err
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(dst)
+		}
+	}()
+	if _, err = CopyMetadata(out, in, CopyAll); err != nil {
+		return report, err
+	}
+	if report.Recorded, err = recordGain(out, in, report.Gain); err != nil {
+		return report, err
+	}
+	out.SetClipping(true)
+
+	var lim *limiter
+	if target.Limit {
+		lim = newLimiter(channels, int(si.Samplerate), math.Pow(10, target.Ceiling/20))
+	}
+	write := func(b []float64) error {
+		if len(b) == 0 {
+			return nil
+		}
+		n, err := out.WriteFrames(b)
+		if err == nil && n != int64(len(b)/channels) {
+			err = errors.New("Normalize: short write")
+		}
+		return err
+	}
+	var werr error
+	err = in.readAll(ctx, func(b []float64) {
+		if werr != nil {
+			return
+		}
+		for i := range b {
+			b[i] *= gain
+		}
+		if lim != nil {
+			b = lim.process(b)
+		}
+		werr = write(b)
+	})
+	if err == nil {
+		err = werr
+	}
+	if err != nil {
+		return report, err
+	}
+	if lim != nil {
+		if err = write(lim.finish()); err != nil {
+			return report, err
+		}
+		report.Limited = lim.reduced
+	}
+	return report, nil
+}
+
+// recordGain notes the gain applied to dst, which must not have had audio written yet, in its coding history if src has a broadcast extension that dst can hold and there is room, and otherwise in its comment. It returns where the note went.
+func recordGain(dst, src *File, gain float64) (string, error) {
+	note := fmt.Sprintf("normalized %+.2f dB", gain)
+	if bi, ok := src.GetBroadcastInfo(); ok && MetadataSupport(dst.Format)&CopyBroadcast != 0 {
+		line := codingHistoryLine(dst.Format, "T="+note)
+		// the history is padded out to an even length
+		for len(bi.Coding_history) > 0 && bi.Coding_history[len(bi.Coding_history)-1] == 0 {
+			bi.Coding_history = bi.Coding_history[:len(bi.Coding_history)-1]
+		}
+		if len(bi.Coding_history)+len(line) <= codingHistorySize {
+			for _, c := range []byte(line) {
+				bi.Coding_history = append(bi.Coding_history, int8(c))
+			}
+			return "bext", dst.SetBroadcastInfo(bi)
+		}
+	}
+	if !stringSupported(dst.Format, Comment) {
+		return "", nil
+	}
+	if c := dst.GetString(Comment); c != "" {
+		note = strings.TrimRight(c, "\n") + "\n" + note
+	}
+	return "comment", dst.SetString(note, Comment)
+}
+
+// codingHistoryLine returns a line of coding history, as EBU R 98 lays it out, for audio of the given format processed as text describes.
+func codingHistoryLine(i Info, text string) string {
+	fields := []string{"A=PCM", fmt.Sprintf("F=%d", i.Samplerate)}
+	switch i.Format & SF_FORMAT_SUBMASK {
+	case SF_FORMAT_FLOAT:
+		fields = append(fields, "W=32")
+	case SF_FORMAT_DOUBLE:
+		fields = append(fields, "W=64")
+	default:
+		if b := ditherBits(i.Format); b != 0 {
+			fields = append(fields, fmt.Sprintf("W=%d", b))
+		}
+	}
+	switch i.Channels {
+	case 1:
+		fields = append(fields, "M=mono")
+	case 2:
+		fields = append(fields, "M=stereo")
+	}
+	return strings.Join(append(fields, text), ",") + "\r\n"
+}
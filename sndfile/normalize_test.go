@@ -0,0 +1,135 @@
+package sndfile
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"testing"
+)
+
+func writeFloats(t *testing.T, name string, i Info, frames []float64, setup func(*File)) {
+	f, err := Open(name, Write, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if setup != nil {
+		setup(f)
+	}
+	if _, err = f.WriteFrames(frames); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+}
+
+func TestNormalizePeak(t *testing.T) {
+	i := Info{Samplerate: 48000, Channels: 1, Format: SF_FORMAT_WAV | SF_FORMAT_PCM_24}
+	writeFloats(t, "normsrc.wav", i, tone(48000, 1, -12, 0), func(f *File) {
+		f.SetString("quiet", Title)
+	})
+	r, err := Normalize(context.Background(), "normsrc.wav", "normdst.wav", Target{Kind: PeakDBFS, Level: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(r.Gain-11) > 0.01 || r.Recorded != "comment" {
+		t.Errorf("unexpected report %+v", r)
+	}
+	f, err := Open("normdst.wav", Read, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	peak, _ := f.CalcNormSignalMax()
+	if p := 20 * math.Log10(peak); math.Abs(p+1) > 0.01 {
+		t.Errorf("peak %g dBFS, expected -1", p)
+	}
+	if f.GetString(Title) != "quiet" || !strings.Contains(f.GetString(Comment), "normalized +11.00 dB") {
+		t.Errorf("title %q and comment %q", f.GetString(Title), f.GetString(Comment))
+	}
+}
+
+func TestNormalizeLoudness(t *testing.T) {
+	i := Info{Samplerate: 48000, Channels: 2, Format: SF_FORMAT_WAV | SF_FORMAT_FLOAT}
+	writeFloats(t, "normsrc.wav", i, tone(5*48000, 2, -30, 0, 1), func(f *File) {
+		f.SetBroadcastInfo(&BroadcastInfo{Description: "tone", Version: 1})
+	})
+	r, err := Normalize(context.Background(), "normsrc.wav", "normdst.wav", Target{Kind: LUFS, Level: -23})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(r.Measured+30) > 0.1 || r.Recorded != "bext" || r.Limited {
+		t.Errorf("unexpected report %+v", r)
+	}
+	f, err := Open("normdst.wav", Read, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	l, _ := f.Loudness(context.Background())
+	if math.Abs(l.Integrated+23) > 0.01 {
+		t.Errorf("normalized to %g LUFS, expected -23", l.Integrated)
+	}
+	bi, ok := f.GetBroadcastInfo()
+	if !ok || bi.Description != "tone" {
+		t.Fatal("broadcast extension not carried over")
+	}
+	var h []byte
+	for _, c := range bi.Coding_history {
+		h = append(h, byte(c))
+	}
+	// libsndfile adds a line of its own for each file it writes, after the note
+	lines := strings.Split(strings.TrimRight(string(h), "\x00"), "\r\n")
+	if want := fmt.Sprintf("A=PCM,F=48000,W=32,M=stereo,T=normalized %+.2f dB", r.Gain); len(lines) != 4 || lines[1] != want {
+		t.Errorf("coding history %q, expected %q as its second line", h, want)
+	}
+}
+
+func TestNormalizeLimit(t *testing.T) {
+	// a -23 LUFS tone with a short loud burst, which +9 dB would take well over full scale
+	a := tone(5*48000, 2, -23, 0, 1)
+	for n := 2 * 48000; n < 2*48000+100; n++ {
+		a[2*n] = 0.8 * math.Sin(2*math.Pi*5000*float64(n)/48000)
+		a[2*n+1] = a[2*n]
+	}
+	i := Info{Samplerate: 48000, Channels: 2, Format: SF_FORMAT_WAV | SF_FORMAT_PCM_24}
+	writeFloats(t, "normsrc.wav", i, a, nil)
+	r, err := Normalize(context.Background(), "normsrc.wav", "normdst.wav", Target{Kind: LUFS, Level: -14, Limit: true, Ceiling: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Limited {
+		t.Error("expected the limiter to act")
+	}
+	f, err := Open("normdst.wav", Read, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if i.Frames != 5*48000 {
+		t.Errorf("limited output has %d frames, expected %d", i.Frames, 5*48000)
+	}
+	tp, _, _ := f.CalcTruePeakAllChannels(context.Background())
+	for c, p := range tp {
+		if p > -0.9 {
+			t.Errorf("channel %d true peak %g dBTP, over the -1 dBTP ceiling", c, p)
+		}
+	}
+	// away from the burst the full gain is applied, and the output is in step with the input
+	b := make([]float64, len(a))
+	f.ReadFrames(b)
+	gain := math.Pow(10, r.Gain/20)
+	for n := 3 * 48000; n < 4*48000; n++ {
+		if d := b[2*n] - a[2*n]*gain; math.Abs(d) > 1e-5 {
+			t.Fatalf("frame %d is %g, expected %g", n, b[2*n], a[2*n]*gain)
+		}
+	}
+
+	writeFloats(t, "normsrc.wav", i, make([]float64, 2*48000), nil)
+	if _, err = Normalize(context.Background(), "normsrc.wav", "normsilent.wav", Target{Kind: LUFS, Level: -23}); err == nil {
+		t.Error("expected an error normalizing silence")
+	}
+	if _, err = os.Stat("normsilent.wav"); err == nil {
+		t.Error("output written for silent source")
+	}
+}
@@ -0,0 +1,131 @@
+package sndfile
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// EmbeddedFile is the location of the audio file within the file that was opened. For a stand-alone file Offset is 0 and Length is the file size.
+type EmbeddedFile struct {
+	Offset int64 `json:"offset"`
+	Length int64 `json:"length"`
+}
+
+// Metadata collects everything libsndfile can tell about a file from its header. Probe fills it in without reading any audio data, so it is cheap enough to run over a whole library.
+type Metadata struct {
+	Info        Info                  `json:"info"`
+	MajorFormat string                `json:"major_format"`
+	Subformat   string                `json:"subformat"`
+	Duration    time.Duration         `json:"duration"`
+	Strings     map[StringType]string `json:"strings,omitempty"`
+	Broadcast   *BroadcastInfo        `json:"broadcast,omitempty"`
+	Cues        []CuePoint            `json:"cues,omitempty"`
+	Instrument  *Instrument           `json:"instrument,omitempty"`
+	Loop        *LoopInfo             `json:"loop,omitempty"`
+	ChannelMap  []int32               `json:"channel_map,omitempty"`
+	Peaks       []float64             `json:"peaks,omitempty"` // from the PEAK chunk, if the file has one
+	Embedded    *EmbeddedFile         `json:"embedded,omitempty"`
+}
+
+// Probe opens the named file for reading, collects its Metadata and closes it again. No audio data is decoded.
+func Probe(path string) (*Metadata, error) {
+	var i Info
+	f, err := Open(path, Read, &i)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return probe(f), nil
+}
+
+// ProbeReader is like Probe but reads the file from r, e.g. an HTTP response body that has been buffered or an archive member. Only the parts of r that libsndfile needs to parse the header are read.
+func ProbeReader(r io.ReadSeeker) (*Metadata, error) {
+	var i Info
+	f, err := OpenVirtual(readSeekerIo(r), Read, &i)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return probe(f), nil
+}
+
+func probe(f *File) *Metadata {
+	m := new(Metadata)
+	m.Info = f.Format
+	_, m.MajorFormat, _, _ = GetFormatInfo(int(f.Format.Format & SF_FORMAT_TYPEMASK))
+	_, m.Subformat, _, _ = GetFormatInfo(int(f.Format.Format & SF_FORMAT_SUBMASK))
+	if f.Format.Samplerate > 0 {
+		m.Duration = time.Duration(float64(f.Format.Frames) / float64(f.Format.Samplerate) * float64(time.Second))
+	}
+	if s := f.Metadata(); len(s) > 0 {
+		m.Strings = s
+	}
+	m.Broadcast, _ = f.GetBroadcastInfo()
+	m.Cues, _ = f.GetCues()
+	m.Instrument = f.GetInstrument()
+	m.Loop = f.GetLoopInfo()
+	if f.Format.Channels > 0 {
+		if cm, err := f.GetChannelMapInfo(); err == nil {
+			m.ChannelMap = cm
+		}
+		if peaks, ok := f.GetMaxAllChannels(); ok {
+			m.Peaks = peaks
+		}
+	}
+	if offset, length, err := f.GetEmbeddedFileInfo(); err == nil {
+		m.Embedded = &EmbeddedFile{offset, length}
+	}
+	return m
+}
+
+// readSeekerIo adapts an io.ReadSeeker to the virtual I/O callbacks.
+func readSeekerIo(r io.ReadSeeker) (v VirtualIo) {
+	v.UserData = r
+	v.GetLength = func(ud interface{}) int64 {
+		r := ud.(io.ReadSeeker)
+		cur, err := r.Seek(0, os.SEEK_CUR)
+		if err != nil {
+			return -1
+		}
+		l, err := r.Seek(0, os.SEEK_END)
+		if err != nil {
+			return -1
+		}
+		if _, err = r.Seek(cur, os.SEEK_SET); err != nil {
+			return -1
+		}
+		return l
+	}
+	v.Seek = func(offset int64, w Whence, ud interface{}) int64 {
+		var whence int
+		switch w {
+		case Set:
+			whence = os.SEEK_SET
+		case Current:
+			whence = os.SEEK_CUR
+		case End:
+			whence = os.SEEK_END
+		}
+		o, err := ud.(io.ReadSeeker).Seek(offset, whence)
+		if err != nil {
+			return -1
+		}
+		return o
+	}
+	v.Read = func(b []byte, ud interface{}) int64 {
+		n, _ := io.ReadFull(ud.(io.ReadSeeker), b)
+		return int64(n)
+	}
+	v.Write = func(b []byte, ud interface{}) int64 {
+		return 0
+	}
+	v.Tell = func(ud interface{}) int64 {
+		o, err := ud.(io.ReadSeeker).Seek(0, os.SEEK_CUR)
+		if err != nil {
+			return -1
+		}
+		return o
+	}
+	return
+}
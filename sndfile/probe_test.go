@@ -0,0 +1,88 @@
+package sndfile
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProbe(t *testing.T) {
+	var i Info
+	i.Format = SF_FORMAT_WAVEX | SF_FORMAT_PCM_16
+	i.Channels = 2
+	i.Samplerate = 8000
+	f, err := Open("probe.wav", Write, &i)
+	if err != nil {
+		t.Fatal("couldn't open file to write", err)
+	}
+	f.SetString("probe title", Title)
+	f.SetString("probe artist", Artist)
+	err = f.SetChannelMapInfo([]int32{ChannelMapLeft, ChannelMapRight})
+	if err != nil {
+		t.Error("couldn't set channel map", err)
+	}
+	f.WriteFrames(make([]int16, 2*4000))
+	f.Close()
+
+	m, err := Probe("probe.wav")
+	if err != nil {
+		t.Fatal("probe failed", err)
+	}
+	if m.Info.Frames != 4000 || m.Info.Channels != 2 || m.Info.Samplerate != 8000 {
+		t.Errorf("bad info %v", m.Info)
+	}
+	if m.Duration != 500*time.Millisecond {
+		t.Errorf("bad duration %v", m.Duration)
+	}
+	if m.Strings[Title] != "probe title" || m.Strings[Artist] != "probe artist" {
+		t.Errorf("bad strings %v", m.Strings)
+	}
+	if !reflect.DeepEqual(m.ChannelMap, []int32{ChannelMapLeft, ChannelMapRight}) {
+		t.Errorf("bad channel map %v", m.ChannelMap)
+	}
+	if m.Instrument != nil || m.Loop != nil || m.Cues != nil {
+		t.Errorf("unexpected instrument, loop or cue info %v %v %v", m.Instrument, m.Loop, m.Cues)
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal("couldn't marshal metadata", err)
+	}
+	if !strings.Contains(string(b), `"title":"probe title"`) {
+		t.Errorf("string keys not readable in json: %s", b)
+	}
+	var back Metadata
+	err = json.Unmarshal(b, &back)
+	if err != nil {
+		t.Fatal("couldn't unmarshal metadata", err)
+	}
+	if !reflect.DeepEqual(back.Strings, m.Strings) {
+		t.Errorf("strings didn't survive json round trip %v %v", back.Strings, m.Strings)
+	}
+}
+
+func TestProbeReader(t *testing.T) {
+	m, err := Probe("test/ok.aiff")
+	if err != nil {
+		t.Fatal("probe failed", err)
+	}
+	if !reflect.DeepEqual(m.Info, goldenInfo()) {
+		t.Errorf("info struct not as expected! %v vs. golden %v", m.Info, goldenInfo())
+	}
+	r, err := os.Open("test/ok.aiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	mr, err := ProbeReader(r)
+	if err != nil {
+		t.Fatal("probe reader failed", err)
+	}
+	// the reader can't know how big the file on disk is
+	m.Embedded, mr.Embedded = nil, nil
+	if !reflect.DeepEqual(m, mr) {
+		t.Errorf("ProbeReader %v differs from Probe %v", mr, m)
+	}
+}
@@ -0,0 +1,265 @@
+package sndfile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// RecorderOptions controls how often a Recorder makes its file safe to read back after a crash.
+type RecorderOptions struct {
+	SyncFrames   int64         // update the header after at least this many frames have been written, never if zero
+	SyncInterval time.Duration // update the header when this long has passed since the last update, never if zero; it is only checked when audio is written, so a stalled recording isn't synced until Write or Sync is called
+	NoJournal    bool          // don't write the journal file alongside the recording
+}
+
+// DefaultRecorderOptions are used when nil options are passed to OpenRecorder.
+var DefaultRecorderOptions = RecorderOptions{SyncInterval: time.Second}
+
+// A Recorder writes a file that survives the process dying part way through. Every so often it updates the file header to cover the audio written so far and flushes the file to disk. Unless disabled it also keeps a journal next to the file (the file name with ".journal" added) describing the recording, which is removed when the Recorder is closed. If a journal is found lying around, the recording was interrupted, and Recover can repair the file.
+type Recorder struct {
+	f        *File
+	path     string
+	opts     RecorderOptions
+	frames   int64
+	synced   int64
+	lastSync time.Time
+	journal  *os.File
+}
+
+type recorderJournal struct {
+	Info    Info      // the format the file was opened with
+	Frames  int64     // frames covered by the header at the last update
+	Updated time.Time // time of the last update
+}
+
+func journalPath(path string) string {
+	return path + ".journal"
+}
+
+// OpenRecorder creates the file at path for writing with the format in info, as Open does. Recover can repair WAV, RF64, AIFF and CAF files holding PCM, float, u-law or a-law data.
+func OpenRecorder(path string, info *Info, opts *RecorderOptions) (r *Recorder, err error) {
+	if opts == nil {
+		opts = &DefaultRecorderOptions
+	}
+	f, err := Open(path, Write, info)
+	if err != nil {
+		return nil, err
+	}
+	r = &Recorder{f: f, path: path, opts: *opts, lastSync: time.Now()}
+	if !opts.NoJournal {
+		if r.journal, err = os.Create(journalPath(path)); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	if err = r.Sync(); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// File returns the underlying file, for setting strings and other metadata. Audio should be written through the Recorder.
+func (r *Recorder) File() *File {
+	return r.f
+}
+
+// Frames returns the number of frames written so far.
+func (r *Recorder) Frames() int64 {
+	return r.frames
+}
+
+// Write writes frames from items, as File.WriteFrames does, then updates the header if one of the sync limits has been reached. There is no timer behind SyncInterval, so a caller that may stop writing for a while should call Sync itself.
+func (r *Recorder) Write(items interface{}) (written int64, err error) {
+	written, err = r.f.WriteFrames(items)
+	r.frames += written
+	if err != nil {
+		return
+	}
+	if (r.opts.SyncFrames > 0 && r.frames-r.synced >= r.opts.SyncFrames) ||
+		(r.opts.SyncInterval > 0 && time.Since(r.lastSync) >= r.opts.SyncInterval) {
+		err = r.Sync()
+	}
+	return
+}
+
+// Sync updates the header to cover all audio written so far, flushes the file to disk and updates the journal.
+func (r *Recorder) Sync() error {
+	if err := r.f.UpdateHeaderNow(); err != nil {
+		return err
+	}
+	r.f.WriteSync()
+	r.synced = r.frames
+	r.lastSync = time.Now()
+	if r.journal == nil {
+		return nil
+	}
+	b, err := json.Marshal(recorderJournal{Info: r.f.Format, Frames: r.frames, Updated: r.lastSync})
+	if err != nil {
+		return err
+	}
+	if err = r.journal.Truncate(0); err != nil {
+		return err
+	}
+	if _, err = r.journal.WriteAt(b, 0); err != nil {
+		return err
+	}
+	return r.journal.Sync()
+}
+
+// Close closes the file and removes the journal. If the file couldn't be closed properly the journal is kept, so that Recover can still repair it.
+func (r *Recorder) Close() (err error) {
+	err = r.f.Close()
+	if r.journal != nil {
+		r.journal.Close()
+		if err == nil {
+			err = os.Remove(journalPath(r.path))
+		}
+		r.journal = nil
+	}
+	return
+}
+
+// Recover repairs the header of a WAV, RF64, AIFF or CAF file whose writer was interrupted before closing it, so that it covers all complete frames in the file. A partial frame at the end is cut off. Files that are intact are left alone. The format is taken from the journal written by a Recorder if there is one, otherwise from the file; the journal is removed once the file has been repaired. Recover returns the number of frames in the repaired file.
+
+// Recover assumes that the audio runs to the end of the file, as it does when a recording is cut off. Any chunks a writer would have added after the audio on close are lost.
+func Recover(path string) (frames int64, err error) {
+	info, err := recoverInfo(path)
+	if err != nil {
+		return 0, err
+	}
+	width := rawSampleWidth(info.Format & SF_FORMAT_SUBMASK)
+	if width <= 0 || info.Channels <= 0 {
+		return 0, fmt.Errorf("Recover: can't recover files of format %#x", info.Format)
+	}
+	blockAlign := int64(width) * int64(info.Channels)
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			os.Remove(journalPath(path))
+		}
+	}()
+	c, err := scanChunks(f)
+	if err != nil {
+		return 0, fmt.Errorf("Recover: %v", err)
+	}
+
+	var audio *chunk
+	var start int64
+	switch c.kind {
+	case riffContainer, rf64Container, cafContainer:
+		audio = c.find("data")
+		if audio != nil {
+			start = audio.data
+			if c.kind == cafContainer {
+				start += 4 // edit count
+			}
+		}
+	case aiffContainer:
+		if audio = c.find("SSND"); audio != nil {
+			var b [4]byte
+			if _, err = f.ReadAt(b[:], audio.data); err != nil {
+				return 0, err
+			}
+			start = audio.data + 8 + int64(c.order.Uint32(b[:]))
+		}
+	default:
+		return 0, errors.New("Recover: only WAV, RF64, AIFF and CAF files can be recovered")
+	}
+	if audio == nil {
+		return 0, errors.New("Recover: no audio chunk in " + path)
+	}
+	if c.clean {
+		return (audio.data + audio.size - start) / blockAlign, nil
+	}
+
+	if start > c.length {
+		start = c.length
+	}
+	n := (c.length - start) / blockAlign * blockAlign
+	frames = n / blockAlign
+	size := start + n - audio.data
+	end := start + n
+	if size&1 != 0 && c.kind != cafContainer {
+		end++
+	}
+	if err = f.Truncate(end); err != nil {
+		return 0, err
+	}
+
+	put32 := func(off int64, v int64) {
+		if err != nil {
+			return
+		}
+		var b [4]byte
+		c.order.PutUint32(b[:], uint32(v))
+		_, err = f.WriteAt(b[:], off)
+	}
+	put64 := func(off int64, v int64) {
+		if err != nil {
+			return
+		}
+		var b [8]byte
+		c.order.PutUint64(b[:], uint64(v))
+		_, err = f.WriteAt(b[:], off)
+	}
+	switch c.kind {
+	case riffContainer:
+		if end-8 > 0xFFFFFFFF {
+			return 0, errors.New("Recover: audio too long for a WAV file")
+		}
+		put32(4, end-8)
+		put32(audio.pos+4, size)
+	case rf64Container:
+		ds64 := c.find("ds64")
+		if ds64 == nil {
+			return 0, errors.New("Recover: no ds64 chunk in " + path)
+		}
+		put64(ds64.data, end-8)
+		put64(ds64.data+8, size)
+		put64(ds64.data+16, frames)
+	case aiffContainer:
+		comm := c.find("COMM")
+		if comm == nil {
+			return 0, errors.New("Recover: no COMM chunk in " + path)
+		}
+		if end-8 > 0xFFFFFFFF {
+			return 0, errors.New("Recover: audio too long for an AIFF file")
+		}
+		put32(4, end-8)
+		put32(comm.data+2, frames)
+		put32(audio.pos+4, size)
+	case cafContainer:
+		put64(audio.pos+4, size)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return frames, f.Sync()
+}
+
+// recoverInfo reads the format of an interrupted recording from its journal, or failing that from the file itself.
+func recoverInfo(path string) (info Info, err error) {
+	if b, err := os.ReadFile(journalPath(path)); err == nil {
+		var j recorderJournal
+		if err = json.Unmarshal(b, &j); err == nil {
+			return j.Info, nil
+		}
+	}
+	f, err := Open(path, Read, &info)
+	if err != nil {
+		return info, fmt.Errorf("Recover: no journal, and %v", err)
+	}
+	f.Close()
+	return info, nil
+}
@@ -0,0 +1,110 @@
+package sndfile
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func copyFile(t *testing.T, dst, src string) {
+	in, err := os.Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	if _, err = io.Copy(out, in); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	formats := []Format{
+		SF_FORMAT_WAV | SF_FORMAT_PCM_16,
+		SF_FORMAT_WAV | SF_FORMAT_PCM_U8,
+		SF_FORMAT_RF64 | SF_FORMAT_PCM_24,
+		SF_FORMAT_AIFF | SF_FORMAT_PCM_16,
+		SF_FORMAT_AIFF | SF_FORMAT_FLOAT,
+		SF_FORMAT_CAF | SF_FORMAT_PCM_16,
+		SF_FORMAT_CAF | SF_FORMAT_ULAW,
+	}
+	for _, format := range formats {
+		i := Info{Samplerate: 8000, Channels: 2, Format: format}
+		r, err := OpenRecorder("recording", &i, &RecorderOptions{SyncFrames: 1000})
+		if err != nil {
+			t.Fatal("couldn't open recorder", err)
+		}
+		r.File().SetString("take 1", Title)
+		want := make([]int16, 2*2500)
+		for n := range want {
+			want[n] = int16(n%256) << 8
+		}
+		// the header is updated after the first 1000 frames, but not after the rest
+		for n := 0; n < len(want); n += 2 * 500 {
+			if _, err = r.Write(want[n : n+2*500]); err != nil {
+				t.Fatal("write failed", err)
+			}
+		}
+		if r.Frames() != 2500 {
+			t.Errorf("recorder counted %d frames, expected 2500", r.Frames())
+		}
+
+		// copying the file and journal now leaves what a crash would
+		copyFile(t, "recordcrash", "recording")
+		copyFile(t, journalPath("recordcrash"), journalPath("recording"))
+		if err = r.Close(); err != nil {
+			t.Error("close failed", err)
+		}
+		if _, err = os.Stat(journalPath("recording")); !os.IsNotExist(err) {
+			t.Error("journal left behind after close")
+		}
+
+		var ci Info
+		f, err := Open("recordcrash", Read, &ci)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		if ci.Frames == 2500 {
+			t.Errorf("%#x: header of interrupted file already covers all frames", format)
+		}
+
+		// a partly written frame at the end should be dropped
+		cf, err := os.OpenFile("recordcrash", os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cf.Write([]byte{1})
+		cf.Close()
+
+		frames, err := Recover("recordcrash")
+		if err != nil {
+			t.Errorf("%#x: recover failed: %v", format, err)
+			continue
+		}
+		if frames != 2500 {
+			t.Errorf("%#x: recovered %d frames, expected 2500", format, frames)
+		}
+		if _, err = os.Stat(journalPath("recordcrash")); !os.IsNotExist(err) {
+			t.Error("journal left behind after recovery")
+		}
+		// u-law isn't lossless, so compare against the file that was closed properly
+		if got := readAllInt16(t, "recordcrash"); !reflect.DeepEqual(got, readAllInt16(t, "recording")) {
+			t.Errorf("%#x: recovered audio differs", format)
+		}
+
+		// recovering an intact file changes nothing
+		before, _ := os.ReadFile("recording")
+		if frames, err = Recover("recording"); err != nil || frames != 2500 {
+			t.Errorf("%#x: recover of intact file returned %d, %v", format, frames, err)
+		}
+		if after, _ := os.ReadFile("recording"); !reflect.DeepEqual(before, after) {
+			t.Errorf("%#x: recover changed an intact file", format)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package sndfile
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// A RemixMatrix gives each output channel as a weighted sum of the input channels: output channel o is the sum over i of m[o][i] times input channel i.
+type RemixMatrix [][]float64
+
+// DefaultChannelMap returns the layout assumed for a file of the given number of channels that has no channel map, the channel map of its DefaultLayout: mono, stereo, L R C, quad, 5.0, 5.1, 7.1 or 7.1.4, in WAV channel order. It returns nil for other channel counts.
+func DefaultChannelMap(channels int) []int32 {
+	if l, ok := DefaultLayout(channels); ok {
+		return l.ChannelMap()
+	}
+	return nil
+}
+
+// Left, Right and Center are the same speakers as FrontLeft, FrontRight and FrontCenter.
+func canonicalPosition(p int32) int32 {
+	switch p {
+	case ChannelMapLeft:
+		return ChannelMapFrontLeft
+	case ChannelMapRight:
+		return ChannelMapFrontRight
+	case ChannelMapCenter, ChannelMapMono:
+		return ChannelMapFrontCenter
+	}
+	return p
+}
+
+const minus3dB = math.Sqrt2 / 2
+
+// Where a speaker missing from the output layout is folded to, in order of preference. Each entry lists the speakers that share it and the gain applied to each.
+var downmixRules = map[int32][][]struct {
+	to   int32
+	gain float64
+}{
+	ChannelMapFrontCenter: {
+		{{ChannelMapFrontLeft, minus3dB}, {ChannelMapFrontRight, minus3dB}},
+	},
+	ChannelMapFrontLeftOfCenter: {
+		{{ChannelMapFrontLeft, minus3dB}, {ChannelMapFrontCenter, minus3dB}},
+		{{ChannelMapFrontLeft, 1}},
+	},
+	ChannelMapFrontRightOfCenter: {
+		{{ChannelMapFrontRight, minus3dB}, {ChannelMapFrontCenter, minus3dB}},
+		{{ChannelMapFrontRight, 1}},
+	},
+	ChannelMapSideLeft: {
+		{{ChannelMapRearLeft, minus3dB}},
+		{{ChannelMapFrontLeft, minus3dB}},
+	},
+	ChannelMapSideRight: {
+		{{ChannelMapRearRight, minus3dB}},
+		{{ChannelMapFrontRight, minus3dB}},
+	},
+	ChannelMapRearLeft: {
+		{{ChannelMapSideLeft, minus3dB}},
+		{{ChannelMapFrontLeft, minus3dB}},
+	},
+	ChannelMapRearRight: {
+		{{ChannelMapSideRight, minus3dB}},
+		{{ChannelMapFrontRight, minus3dB}},
+	},
+	ChannelMapRearCenter: {
+		{{ChannelMapRearLeft, minus3dB}, {ChannelMapRearRight, minus3dB}},
+		{{ChannelMapSideLeft, minus3dB}, {ChannelMapSideRight, minus3dB}},
+		{{ChannelMapFrontLeft, 0.5}, {ChannelMapFrontRight, 0.5}},
+	},
+	ChannelMapTopCenter: {
+		{{ChannelMapFrontCenter, minus3dB}},
+		{{ChannelMapFrontLeft, 0.5}, {ChannelMapFrontRight, 0.5}},
+	},
+	ChannelMapTopFrontLeft:   {{{ChannelMapFrontLeft, minus3dB}}},
+	ChannelMapTopFrontRight:  {{{ChannelMapFrontRight, minus3dB}}},
+	ChannelMapTopFrontCenter: {{{ChannelMapFrontCenter, minus3dB}}, {{ChannelMapFrontLeft, 0.5}, {ChannelMapFrontRight, 0.5}}},
+	ChannelMapTopRearLeft:    {{{ChannelMapRearLeft, minus3dB}}, {{ChannelMapSideLeft, minus3dB}}, {{ChannelMapFrontLeft, 0.5}}},
+	ChannelMapTopRearRight:   {{{ChannelMapRearRight, minus3dB}}, {{ChannelMapSideRight, minus3dB}}, {{ChannelMapFrontRight, 0.5}}},
+	ChannelMapTopRearCenter:  {{{ChannelMapRearCenter, minus3dB}}, {{ChannelMapRearLeft, 0.5}, {ChannelMapRearRight, 0.5}}, {{ChannelMapFrontLeft, 0.5}, {ChannelMapFrontRight, 0.5}}},
+}
+
+// RemixMatrixFor returns the matrix that converts audio in the layout from to the layout to, both given as ChannelMap constants.
+
+// Speakers present in both layouts are copied across. Speakers missing from the output are folded into their neighbours with the ITU-R BS.775 downmix gains: centre into left and right at -3 dB, surrounds into the fronts at -3 dB, and side and rear surrounds into each other at -3 dB. Height speakers fold into the ear level speaker below them. The LFE channel is dropped unless the output has one, as the ITU downmix leaves it out. When the output is mono, the audio is first folded to stereo and left and right are then averaged, so that a signal present in both comes out at the same level. No gain is applied to keep the result from clipping.
+func RemixMatrixFor(from, to []int32) (RemixMatrix, error) {
+	m := make(RemixMatrix, len(to))
+	for o := range m {
+		m[o] = make([]float64, len(from))
+	}
+	if len(to) == 1 && len(from) > 1 && canonicalPosition(to[0]) == ChannelMapFrontCenter {
+		stereo, err := RemixMatrixFor(from, []int32{ChannelMapFrontLeft, ChannelMapFrontRight})
+		if err != nil {
+			return nil, err
+		}
+		for i := range from {
+			m[0][i] = (stereo[0][i] + stereo[1][i]) / 2
+		}
+		return m, nil
+	}
+
+	out := make(map[int32]int, len(to))
+	for o, p := range to {
+		out[canonicalPosition(p)] = o
+	}
+	for i, p := range from {
+		p = canonicalPosition(p)
+		if o, ok := out[p]; ok {
+			m[o][i] = 1
+			continue
+		}
+		if p == ChannelMapLfe {
+			continue
+		}
+		placed := false
+		for _, rule := range downmixRules[p] {
+			all := true
+			for _, t := range rule {
+				if _, ok := out[t.to]; !ok {
+					all = false
+				}
+			}
+			if !all {
+				continue
+			}
+			for _, t := range rule {
+				m[out[t.to]][i] += t.gain
+			}
+			placed = true
+			break
+		}
+		if !placed {
+			return nil, fmt.Errorf("RemixMatrixFor: no place for channel %d (position %d) in the output layout", i, from[i])
+		}
+	}
+	return m, nil
+}
+
+// A Remixer reads audio from a File with its channels remixed through a RemixMatrix.
+type Remixer struct {
+	Format Info // the output format: the source's, with the new number of channels
+
+	read       func([]float64) (int64, error)
+	matrix     RemixMatrix
+	channelMap []int32
+	in         []float64
+}
+
+// NewRemixer returns a Remixer that reads src, which must be open for reading, converted to the layout to. The source layout is the file's channel map, or if it has none the DefaultChannelMap for its channel count. Samples are returned as float64, normalised as src is set to normalise them.
+func NewRemixer(src *File, to []int32) (*Remixer, error) {
+	return newRemixer(func(b []float64) (int64, error) { return src.ReadFrames(b) }, src.Format, fileChannelMap(src), to)
+}
+
+// NewRemixerMatrix returns a Remixer that reads src through the matrix m, which must have a column for each channel of src.
+func NewRemixerMatrix(src *File, m RemixMatrix) (*Remixer, error) {
+	return newRemixerMatrix(func(b []float64) (int64, error) { return src.ReadFrames(b) }, src.Format, m)
+}
+
+// fileChannelMap returns the layout of f, from its channel map or failing that from its channel count.
+func fileChannelMap(f *File) []int32 {
+	if cm, err := f.GetChannelMapInfo(); err == nil {
+		for _, p := range cm {
+			if p == ChannelMapInvalid {
+				return DefaultChannelMap(int(f.Format.Channels))
+			}
+		}
+		return cm
+	}
+	return DefaultChannelMap(int(f.Format.Channels))
+}
+
+func newRemixer(read func([]float64) (int64, error), format Info, from, to []int32) (*Remixer, error) {
+	if from == nil {
+		return nil, fmt.Errorf("NewRemixer: no channel map for %d channels", format.Channels)
+	}
+	m, err := RemixMatrixFor(from, to)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newRemixerMatrix(read, format, m)
+	if err != nil {
+		return nil, err
+	}
+	r.channelMap = append([]int32(nil), to...)
+	return r, nil
+}
+
+func newRemixerMatrix(read func([]float64) (int64, error), format Info, m RemixMatrix) (*Remixer, error) {
+	if len(m) == 0 {
+		return nil, errors.New("NewRemixer: empty matrix")
+	}
+	for _, row := range m {
+		if len(row) != int(format.Channels) {
+			return nil, fmt.Errorf("NewRemixer: matrix row has %d columns for %d input channels", len(row), format.Channels)
+		}
+	}
+	r := &Remixer{read: read, matrix: m}
+	r.Format = format
+	r.Format.Channels = int32(len(m))
+	return r, nil
+}
+
+// ChannelMap returns the layout of the output, or nil if the Remixer was made from a matrix.
+func (r *Remixer) ChannelMap() []int32 {
+	return r.channelMap
+}
+
+// ReadFrames fills out with remixed frames and returns the number of frames read.
+func (r *Remixer) ReadFrames(out []float64) (read int64, err error) {
+	outCh := len(r.matrix)
+	inCh := len(r.matrix[0])
+	frames := len(out) / outCh
+	if len(r.in) < frames*inCh {
+		r.in = make([]float64, frames*inCh)
+	}
+	read, err = r.read(r.in[:frames*inCh])
+	for f := 0; f < int(read); f++ {
+		in := r.in[f*inCh : (f+1)*inCh]
+		for o, row := range r.matrix {
+			var s float64
+			for i, g := range row {
+				s += g * in[i]
+			}
+			out[f*outCh+o] = s
+		}
+	}
+	return
+}
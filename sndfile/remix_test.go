@@ -0,0 +1,102 @@
+package sndfile
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestRemixMatrixFor(t *testing.T) {
+	const g = minus3dB
+	cases := []struct {
+		name     string
+		from, to []int32
+		want     RemixMatrix
+	}{
+		{"5.1 to stereo", DefaultChannelMap(6), DefaultChannelMap(2), RemixMatrix{
+			{1, 0, g, 0, g, 0},
+			{0, 1, g, 0, 0, g},
+		}},
+		{"stereo to mono", DefaultChannelMap(2), DefaultChannelMap(1), RemixMatrix{{0.5, 0.5}}},
+		{"mono to stereo", DefaultChannelMap(1), DefaultChannelMap(2), RemixMatrix{{g}, {g}}},
+		{"mono to 5.1", DefaultChannelMap(1), DefaultChannelMap(6), RemixMatrix{{0}, {0}, {1}, {0}, {0}, {0}}},
+		{"7.1 to 5.1", DefaultChannelMap(8), DefaultChannelMap(6), RemixMatrix{
+			{1, 0, 0, 0, 0, 0, 0, 0},
+			{0, 1, 0, 0, 0, 0, 0, 0},
+			{0, 0, 1, 0, 0, 0, 0, 0},
+			{0, 0, 0, 1, 0, 0, 0, 0},
+			{0, 0, 0, 0, 1, 0, g, 0},
+			{0, 0, 0, 0, 0, 1, 0, g},
+		}},
+		{"5.1 to mono", DefaultChannelMap(6), DefaultChannelMap(1), RemixMatrix{{0.5, 0.5, g, 0, g / 2, g / 2}}},
+	}
+	for _, c := range cases {
+		m, err := RemixMatrixFor(c.from, c.to)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(m, c.want) {
+			t.Errorf("%s: got %v, expected %v", c.name, m, c.want)
+		}
+	}
+	if _, err := RemixMatrixFor([]int32{ChannelMapSideLeft}, []int32{ChannelMapFrontRight}); err == nil {
+		t.Error("expected an error for a channel with nowhere to go")
+	}
+}
+
+func TestConvertRemix(t *testing.T) {
+	i := Info{Samplerate: 8000, Channels: 6, Format: SF_FORMAT_WAVEX | SF_FORMAT_FLOAT}
+	f, err := Open("remixsrc.wav", Write, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.SetChannelMapInfo(DefaultChannelMap(6))
+	frame := []float64{0.1, 0.2, 0.3, 0.9, 0.4, 0.5}
+	for n := 0; n < 100; n++ {
+		f.WriteFrames(frame)
+	}
+	f.Close()
+
+	err = Convert(context.Background(), "remixsrc.wav", "remixdst.wav", Info{Channels: 2, Format: SF_FORMAT_WAVEX | SF_FORMAT_FLOAT}, nil)
+	if err != nil {
+		t.Fatal("convert failed", err)
+	}
+	f, err = Open("remixdst.wav", Read, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if i.Channels != 2 || i.Frames != 100 {
+		t.Fatalf("expected 100 stereo frames, got %d of %d channels", i.Frames, i.Channels)
+	}
+	if cm, err := f.GetChannelMapInfo(); err != nil || !reflect.DeepEqual(cm, []int32{ChannelMapLeft, ChannelMapRight}) {
+		t.Errorf("expected a stereo channel map, got %v %v", cm, err)
+	}
+	out := make([]float64, 2)
+	f.ReadFrames(out)
+	want := []float64{0.1 + minus3dB*0.3 + minus3dB*0.4, 0.2 + minus3dB*0.3 + minus3dB*0.5}
+	for c := range want {
+		if math.Abs(out[c]-want[c]) > 1e-6 {
+			t.Errorf("downmixed frame is %v, expected %v", out, want)
+			break
+		}
+	}
+
+	// swapping left and right with a custom matrix
+	opts := DefaultConvertOptions
+	opts.Matrix = RemixMatrix{{0, 1}, {1, 0}}
+	if err = Convert(context.Background(), "remixdst.wav", "remixswap.wav", Info{Format: SF_FORMAT_WAV | SF_FORMAT_FLOAT}, &opts); err != nil {
+		t.Fatal("convert failed", err)
+	}
+	sf, err := Open("remixswap.wav", Read, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sf.Close()
+	sf.ReadFrames(out)
+	if math.Abs(out[0]-want[1]) > 1e-6 || math.Abs(out[1]-want[0]) > 1e-6 {
+		t.Errorf("swapped frame is %v, expected %v", out, []float64{want[1], want[0]})
+	}
+}
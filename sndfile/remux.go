@@ -0,0 +1,228 @@
+package sndfile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// rawSampleWidth returns the number of bytes per sample for sub formats whose data can be copied between containers without decoding, 0 for the block based ADPCM codecs and -1 for anything else.
+func rawSampleWidth(sub Format) int {
+	switch sub {
+	case SF_FORMAT_PCM_S8, SF_FORMAT_PCM_U8, SF_FORMAT_ULAW, SF_FORMAT_ALAW:
+		return 1
+	case SF_FORMAT_PCM_16:
+		return 2
+	case SF_FORMAT_PCM_24:
+		return 3
+	case SF_FORMAT_PCM_32, SF_FORMAT_FLOAT:
+		return 4
+	case SF_FORMAT_DOUBLE:
+		return 8
+	case SF_FORMAT_IMA_ADPCM, SF_FORMAT_MS_ADPCM:
+		return 0
+	}
+	return -1
+}
+
+// The ADPCM block layout is shared by the RIFF style containers only.
+func isRiffFamily(major Format) bool {
+	return major == SF_FORMAT_WAV || major == SF_FORMAT_W64 || major == SF_FORMAT_RF64
+}
+
+// Remux moves the encoded audio in src into a new file dst of major format dstMajor without decoding and re-encoding it. PCM, float, u-law and a-law data can move between WAV, W64, RF64, CAF and AIFF; IMA and MS ADPCM only between WAV, W64 and RF64. Samples are byte swapped if the containers differ in endianness, and 8 bit data is converted between signed and unsigned where the destination requires it. Metadata is copied where dst can hold it.
+
+// Once written, dst is decoded and compared against src. If they differ in any sample, dst is removed and an error is returned.
+func Remux(src, dst string, dstMajor Format) (err error) {
+	dstMajor &= SF_FORMAT_TYPEMASK
+	var si Info
+	in, err := Open(src, Read, &si)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	srcMajor := si.Format & SF_FORMAT_TYPEMASK
+	sub := si.Format & SF_FORMAT_SUBMASK
+	width := rawSampleWidth(sub)
+	if width < 0 {
+		return fmt.Errorf("Remux: can't copy encoded data of format %#x", sub)
+	}
+	if width == 0 && !(isRiffFamily(srcMajor) && isRiffFamily(dstMajor)) {
+		return errors.New("Remux: ADPCM data can only be moved between WAV, W64 and RF64")
+	}
+
+	di := Info{Samplerate: si.Samplerate, Channels: si.Channels, Format: dstMajor | sub}
+	flipSign := false
+	if !FormatCheck(di) && (sub == SF_FORMAT_PCM_U8 || sub == SF_FORMAT_PCM_S8) {
+		// WAV only does unsigned 8 bit, AIFF and CAF only signed
+		di.Format = dstMajor | (SF_FORMAT_PCM_U8 ^ SF_FORMAT_PCM_S8 ^ sub)
+		flipSign = true
+	}
+	if !FormatCheck(di) {
+		return fmt.Errorf("Remux: format %#x can't hold %#x data", dstMajor, sub)
+	}
+
+	// libsndfile's ADPCM decoders read ahead a block when the file is opened, so ReadRaw would miss the start of the data. Read the data chunk directly instead.
+	blockAlign := int(si.Channels) * width
+	var raw io.Reader = rawReader{in}
+	if width == 0 {
+		var offset, length int64
+		blockAlign, offset, length, err = riffDataLayout(src)
+		if err != nil {
+			return err
+		}
+		rf, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer rf.Close()
+		raw = io.NewSectionReader(rf, offset, length)
+	}
+
+	out, err := Open(dst, Write, &di)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if out != nil {
+			out.Close()
+		}
+		if err != nil {
+			os.Remove(dst)
+		}
+	}()
+	if width == 0 {
+		// libsndfile picks the ADPCM block size from the sample rate, so a file from another encoder may not match the fmt chunk just written to dst
+		var dstAlign int
+		if dstAlign, _, _, err = riffDataLayout(dst); err != nil {
+			return err
+		}
+		if dstAlign != blockAlign {
+			return fmt.Errorf("Remux: %s has ADPCM blocks of %d bytes, libsndfile writes %d", src, blockAlign, dstAlign)
+		}
+	}
+	if _, err = CopyMetadata(out, in, CopyAll); err != nil {
+		return err
+	}
+
+	swap := width > 1 && in.RawNeedsEndianSwap() != out.RawNeedsEndianSwap()
+	buf := make([]byte, blockAlign*(65536/blockAlign+1))
+	for {
+		n, err := io.ReadFull(raw, buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		b := buf[:n]
+		if swap {
+			swapBytes(b, width)
+		}
+		if flipSign {
+			for i := range b {
+				b[i] ^= 0x80
+			}
+		}
+		if _, err = out.WriteRaw(b); err != nil {
+			return err
+		}
+	}
+	err = out.Close()
+	out = nil
+	if err != nil {
+		return err
+	}
+	return compareDecoded(src, dst)
+}
+
+// rawReader lets ReadRaw be used as an io.Reader.
+type rawReader struct {
+	f *File
+}
+
+func (r rawReader) Read(b []byte) (int, error) {
+	n, err := r.f.ReadRaw(b)
+	if err == nil && n == 0 {
+		err = io.EOF
+	}
+	return int(n), err
+}
+
+func swapBytes(b []byte, width int) {
+	for i := 0; i+width <= len(b); i += width {
+		s := b[i : i+width]
+		for l, r := 0, width-1; l < r; l, r = l+1, r-1 {
+			s[l], s[r] = s[r], s[l]
+		}
+	}
+}
+
+// compareDecoded checks that two files decode to exactly the same samples.
+func compareDecoded(a, b string) error {
+	var ai, bi Info
+	af, err := Open(a, Read, &ai)
+	if err != nil {
+		return err
+	}
+	defer af.Close()
+	bf, err := Open(b, Read, &bi)
+	if err != nil {
+		return err
+	}
+	defer bf.Close()
+	if ai.Frames != bi.Frames || ai.Channels != bi.Channels || ai.Samplerate != bi.Samplerate {
+		return fmt.Errorf("Remux: output has %d frames of %d channels at %d Hz, input %d frames of %d channels at %d Hz", bi.Frames, bi.Channels, bi.Samplerate, ai.Frames, ai.Channels, ai.Samplerate)
+	}
+	af.SetDoubleNormalization(false)
+	bf.SetDoubleNormalization(false)
+	abuf := make([]float64, 4096*int(ai.Channels))
+	bbuf := make([]float64, len(abuf))
+	var pos int64
+	for {
+		an, err := af.ReadFrames(abuf)
+		if err != nil {
+			return err
+		}
+		bn, err := bf.ReadFrames(bbuf)
+		if err != nil {
+			return err
+		}
+		if an != bn {
+			return fmt.Errorf("Remux: output length differs after frame %d", pos)
+		}
+		if an == 0 {
+			return nil
+		}
+		for i := 0; i < int(an)*int(ai.Channels); i++ {
+			if abuf[i] != bbuf[i] {
+				return fmt.Errorf("Remux: output differs from input at frame %d", pos+int64(i/int(ai.Channels)))
+			}
+		}
+		pos += an
+	}
+}
+
+// riffDataLayout reads the block alignment and the position and length of the audio data from the header of a WAV, RF64 or W64 file.
+func riffDataLayout(name string) (blockAlign int, dataOffset, dataLen int64, err error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	c, err := scanChunks(f)
+	if err != nil {
+		return
+	}
+	fmtChunk, data := c.find("fmt "), c.find("data")
+	if c.kind == aiffContainer || c.kind == cafContainer || fmtChunk == nil || fmtChunk.size < 14 || data == nil {
+		return 0, 0, 0, errors.New("Remux: couldn't find fmt and data chunks in " + name)
+	}
+	var b [2]byte
+	if _, err = f.ReadAt(b[:], fmtChunk.data+12); err != nil {
+		return
+	}
+	return int(c.order.Uint16(b[:])), data.data, data.size, nil
+}
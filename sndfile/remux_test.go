@@ -0,0 +1,81 @@
+package sndfile
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRemux(t *testing.T) {
+	cases := []struct {
+		sub    Format
+		majors []Format
+	}{
+		{SF_FORMAT_PCM_16, []Format{SF_FORMAT_AIFF, SF_FORMAT_CAF, SF_FORMAT_W64, SF_FORMAT_RF64}},
+		{SF_FORMAT_PCM_24, []Format{SF_FORMAT_AIFF, SF_FORMAT_CAF}},
+		{SF_FORMAT_PCM_U8, []Format{SF_FORMAT_AIFF, SF_FORMAT_W64}},
+		{SF_FORMAT_FLOAT, []Format{SF_FORMAT_AIFF, SF_FORMAT_CAF}},
+		{SF_FORMAT_DOUBLE, []Format{SF_FORMAT_CAF}},
+		{SF_FORMAT_ULAW, []Format{SF_FORMAT_AIFF, SF_FORMAT_CAF}},
+		{SF_FORMAT_ALAW, []Format{SF_FORMAT_W64}},
+		{SF_FORMAT_IMA_ADPCM, []Format{SF_FORMAT_W64}},
+		{SF_FORMAT_MS_ADPCM, []Format{SF_FORMAT_W64}},
+	}
+	for _, c := range cases {
+		writeRamp(t, "remuxsrc.wav", Info{Samplerate: 8000, Channels: 2, Format: SF_FORMAT_WAV | c.sub}, 5000)
+		want := readAllInt16(t, "remuxsrc.wav")
+		for _, m := range c.majors {
+			err := Remux("remuxsrc.wav", "remuxdst", m)
+			if err != nil {
+				t.Errorf("remux of %#x to %#x failed: %v", c.sub, m, err)
+				continue
+			}
+			var i Info
+			f, err := Open("remuxdst", Read, &i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.Close()
+			if i.Format&SF_FORMAT_TYPEMASK != m {
+				t.Errorf("remuxed file has format %#x, expected major %#x", i.Format, m)
+			}
+			if got := readAllInt16(t, "remuxdst"); !reflect.DeepEqual(got, want) {
+				t.Errorf("remux of %#x to %#x changed the audio", c.sub, m)
+			}
+		}
+	}
+}
+
+func TestRemuxUnsupported(t *testing.T) {
+	writeRamp(t, "remuxsrc.wav", Info{Samplerate: 8000, Channels: 1, Format: SF_FORMAT_WAV | SF_FORMAT_IMA_ADPCM}, 1000)
+	os.Remove("remuxdst")
+	if err := Remux("remuxsrc.wav", "remuxdst", SF_FORMAT_AIFF); err == nil {
+		t.Error("expected ADPCM remux to AIFF to fail")
+	}
+	if _, err := os.Stat("remuxdst"); !os.IsNotExist(err) {
+		t.Error("failed remux left output behind")
+	}
+}
+
+func TestRemuxBlockAlign(t *testing.T) {
+	// at 44100 Hz libsndfile writes 2048 byte IMA blocks; claim 8000 Hz so the destination gets 256 byte ones
+	writeRamp(t, "remuxsrc.wav", Info{Samplerate: 44100, Channels: 1, Format: SF_FORMAT_WAV | SF_FORMAT_IMA_ADPCM}, 5000)
+	b, err := ioutil.ReadFile("remuxsrc.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary.LittleEndian.PutUint32(b[24:], 8000)
+	if err = ioutil.WriteFile("remuxsrc.wav", b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove("remuxdst")
+	if err := Remux("remuxsrc.wav", "remuxdst", SF_FORMAT_W64); err == nil || !strings.Contains(err.Error(), "2048") {
+		t.Errorf("expected a block size mismatch, got %v", err)
+	}
+	if _, err := os.Stat("remuxdst"); !os.IsNotExist(err) {
+		t.Error("failed remux left output behind")
+	}
+}
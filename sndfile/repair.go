@@ -0,0 +1,339 @@
+package sndfile
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// HeaderProblem describes what Repair found wrong with a file. The values may be ORed together.
+type HeaderProblem int
+
+const (
+	BadDataSize      HeaderProblem = 1 << iota // the audio chunk claims more data than the file holds, or less than follows it
+	MissingDataSize                            // the audio chunk size is zero or unset, as left by a writer that never finished
+	BadPadding                                 // a chunk of odd size isn't followed by a pad byte
+	TrailingGarbage                            // the file ends in bytes that don't form a chunk
+	BadContainerSize                           // the RIFF or FORM size doesn't match the chunks
+	BadFrameCount                              // the AIFF COMM chunk frame count doesn't match the audio
+	PartialFrame                               // the audio ends part way through a frame
+)
+
+var headerProblemNames = []string{"data size", "missing data size", "padding", "trailing garbage", "container size", "frame count", "partial frame"}
+
+func (p HeaderProblem) String() string {
+	var s []string
+	for i, n := range headerProblemNames {
+		if p&(1<<uint(i)) != 0 {
+			s = append(s, n)
+		}
+	}
+	if s == nil {
+		return "none"
+	}
+	return strings.Join(s, "|")
+}
+
+// RepairOptions controls what Repair does with a broken file.
+type RepairOptions struct {
+	Output     string // write the repaired file here and leave the original alone. If empty the file is repaired in place.
+	DryRun     bool   // only report problems, don't write anything
+	ExtendData bool   // treat bytes following a sized audio chunk that don't form a chunk as audio the header failed to count, rather than as garbage
+}
+
+// RepairReport says what Repair found.
+type RepairReport struct {
+	Problems HeaderProblem
+	Frames   int64    // frames in the repaired file
+	Log      string   // libsndfile's log from opening the file before repair
+	Warnings []string // libsndfile's complaints about the file, from the log
+	Written  bool     // whether a repaired file was written
+}
+
+// Repair checks the chunk structure of a WAV or AIFF file against its contents and rewrites the header if they disagree. Audio chunk sizes that are missing or wrong, missing pad bytes, garbage at the end of the file, partial frames and wrong container sizes and AIFF frame counts are all fixed; chunks are otherwise copied as they are.
+
+// A file with no problems is not rewritten. When repairing in place the repaired file is written next to the original and renamed over it.
+func Repair(path string, opts *RepairOptions) (report RepairReport, err error) {
+	if opts == nil {
+		opts = new(RepairOptions)
+	}
+	var info Info
+	sf, oerr := Open(path, Read, &info)
+	report.Log, _ = sf.GetLogInfo()
+	if oerr == nil {
+		sf.Close()
+	}
+	report.Warnings = logWarnings(ParseLogInfo(report.Log))
+
+	in, err := os.Open(path)
+	if err != nil {
+		return report, err
+	}
+	defer in.Close()
+	st, err := in.Stat()
+	if err != nil {
+		return report, err
+	}
+	l, err := parseForRepair(in, st.Size(), opts.ExtendData)
+	if err != nil {
+		return report, err
+	}
+	report.Problems = l.problems
+	report.Frames = l.frames
+	if report.Problems == 0 || opts.DryRun {
+		return report, nil
+	}
+
+	out := opts.Output
+	if out == "" {
+		out = path + ".repair"
+	}
+	w, err := os.Create(out)
+	if err != nil {
+		return report, err
+	}
+	err = l.write(w, in)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && opts.Output == "" {
+		// os.Create gave the new file default permissions; it is taking the original's place
+		if err = os.Chmod(out, st.Mode().Perm()); err == nil {
+			err = os.Rename(out, path)
+		}
+	}
+	if err != nil {
+		os.Remove(out)
+		return report, err
+	}
+	report.Written = true
+	return report, nil
+}
+
+// repairLayout is what parseForRepair makes of a file: the chunks worth keeping, with the sizes they should have.
+type repairLayout struct {
+	order     binary.ByteOrder
+	riffID    string
+	formType  string
+	spans     []chunk // data is the body offset in the original file, size the number of body bytes to keep
+	audio     int     // index of the audio chunk in spans
+	comm      int     // index of the AIFF COMM chunk, -1 for WAV
+	frames    int64
+	problems  HeaderProblem
+	outerSize int64 // RIFF or FORM size in the original header
+}
+
+func parseForRepair(r io.ReaderAt, length int64, extend bool) (l *repairLayout, err error) {
+	var hdr [12]byte
+	if _, err = r.ReadAt(hdr[:], 0); err != nil {
+		return nil, errors.New("Repair: file too short")
+	}
+	l = &repairLayout{riffID: string(hdr[:4]), formType: string(hdr[8:]), audio: -1, comm: -1}
+	audioID := "data"
+	switch {
+	case l.riffID == "RIFF" && l.formType == "WAVE":
+		l.order = binary.LittleEndian
+	case l.riffID == "RIFX" && l.formType == "WAVE":
+		l.order = binary.BigEndian
+	case l.riffID == "FORM" && (l.formType == "AIFF" || l.formType == "AIFC"):
+		l.order = binary.BigEndian
+		audioID = "SSND"
+	default:
+		return nil, errors.New("Repair: only WAV and AIFF files can be repaired")
+	}
+	l.outerSize = int64(l.order.Uint32(hdr[4:]))
+
+	header := func(pos int64) (id string, size int64, ok bool) {
+		var h [8]byte
+		if pos+8 > length {
+			return
+		}
+		if _, err := r.ReadAt(h[:], pos); err != nil || !printableID(h[:4]) {
+			return
+		}
+		id, size = string(h[:4]), int64(l.order.Uint32(h[4:]))
+		return id, size, id == audioID || pos+8+size <= length
+	}
+
+	pos := int64(12)
+	for pos < length {
+		id, size, ok := header(pos)
+		if !ok {
+			if l.audio >= 0 && l.audio == len(l.spans)-1 && extend {
+				a := &l.spans[l.audio]
+				a.size = length - a.data
+				l.problems |= BadDataSize
+			} else {
+				l.problems |= TrailingGarbage
+			}
+			break
+		}
+		c := chunk{id: id, pos: pos, data: pos + 8, size: size}
+		sized := false // size worked out here rather than taken from the header
+		if id == audioID && l.audio < 0 {
+			_, _, followed := header(c.data + size)
+			switch {
+			case (size == 0 || size == 0xFFFFFFFF) && !followed && c.data < length:
+				l.problems |= MissingDataSize
+				c.size = length - c.data
+				sized = true
+			case c.data+size > length:
+				l.problems |= BadDataSize
+				c.size = length - c.data
+				sized = true
+			}
+			l.audio = len(l.spans)
+		}
+		if id == "COMM" && l.comm < 0 {
+			l.comm = len(l.spans)
+		}
+		l.spans = append(l.spans, c)
+		next := c.data + c.size
+		if c.size&1 != 0 {
+			if next+1 == length {
+				next++
+			} else if _, _, ok := header(next + 1); ok {
+				next++
+			} else if _, _, ok := header(next); ok || (next == length && !sized) {
+				l.problems |= BadPadding
+			} else {
+				next++
+			}
+		}
+		pos = next
+	}
+	if l.audio < 0 {
+		return nil, errors.New("Repair: no audio chunk found")
+	}
+
+	blockAlign, prefix, err := l.audioLayout(r)
+	if err != nil {
+		return nil, err
+	}
+	a := &l.spans[l.audio]
+	if a.size < prefix {
+		return nil, errors.New("Repair: audio chunk too short")
+	}
+	if rem := (a.size - prefix) % blockAlign; rem != 0 {
+		l.problems |= PartialFrame
+		a.size -= rem
+	}
+	l.frames = (a.size - prefix) / blockAlign
+
+	if l.comm >= 0 {
+		var b [4]byte
+		if _, err = r.ReadAt(b[:], l.spans[l.comm].data+2); err != nil {
+			return nil, err
+		}
+		if int64(l.order.Uint32(b[:])) != l.frames {
+			l.problems |= BadFrameCount
+		}
+	}
+	if l.outerSize != l.length()-8 {
+		l.problems |= BadContainerSize
+	}
+	return l, nil
+}
+
+// audioLayout returns the size of a frame and the number of bytes at the start of the audio chunk body that aren't audio.
+func (l *repairLayout) audioLayout(r io.ReaderAt) (blockAlign, prefix int64, err error) {
+	if l.comm < 0 {
+		for _, c := range l.spans {
+			if c.id == "fmt " && c.size >= 14 {
+				var b [2]byte
+				if _, err = r.ReadAt(b[:], c.data+12); err != nil {
+					return
+				}
+				if blockAlign = int64(l.order.Uint16(b[:])); blockAlign > 0 {
+					return blockAlign, 0, nil
+				}
+			}
+		}
+		return 0, 0, errors.New("Repair: no usable fmt chunk")
+	}
+
+	comm := l.spans[l.comm]
+	var b [22]byte
+	if comm.size < 18 {
+		return 0, 0, errors.New("Repair: COMM chunk too short")
+	}
+	if _, err = r.ReadAt(b[:18], comm.data); err != nil {
+		return
+	}
+	channels, bits := int64(l.order.Uint16(b[0:])), int64(l.order.Uint16(b[6:]))
+	width := (bits + 7) / 8
+	if l.formType == "AIFC" && comm.size >= 22 {
+		if _, err = r.ReadAt(b[18:22], comm.data+18); err != nil {
+			return
+		}
+		switch strings.ToLower(string(b[18:22])) {
+		case "ulaw", "alaw":
+			width = 1
+		case "fl32":
+			width = 4
+		case "fl64":
+			width = 8
+		case "none", "sowt", "raw ", "in24", "in32", "twos":
+		default:
+			return 0, 0, errors.New("Repair: can't repair compressed AIFC files")
+		}
+	}
+	if channels <= 0 || width <= 0 {
+		return 0, 0, errors.New("Repair: bad COMM chunk")
+	}
+	if _, err = r.ReadAt(b[:4], l.spans[l.audio].data); err != nil {
+		return
+	}
+	return channels * width, 8 + int64(l.order.Uint32(b[:4])), nil
+}
+
+// length returns the size of the repaired file.
+func (l *repairLayout) length() int64 {
+	n := int64(12)
+	for _, c := range l.spans {
+		n += 8 + c.size + c.size&1
+	}
+	return n
+}
+
+func (l *repairLayout) write(w io.Writer, r io.ReaderAt) error {
+	var b [8]byte
+	copy(b[:], l.riffID)
+	l.order.PutUint32(b[4:], uint32(l.length()-8))
+	if _, err := w.Write(b[:]); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, l.formType); err != nil {
+		return err
+	}
+	for i, c := range l.spans {
+		copy(b[:], c.id)
+		l.order.PutUint32(b[4:], uint32(c.size))
+		if _, err := w.Write(b[:]); err != nil {
+			return err
+		}
+		body := io.NewSectionReader(r, c.data, c.size)
+		if i == l.comm {
+			// channels, then the frame count
+			if _, err := io.CopyN(w, body, 2); err != nil {
+				return err
+			}
+			l.order.PutUint32(b[:4], uint32(l.frames))
+			if _, err := w.Write(b[:4]); err != nil {
+				return err
+			}
+			body.Seek(6, os.SEEK_SET)
+		}
+		if _, err := io.Copy(w, body); err != nil {
+			return err
+		}
+		if c.size&1 != 0 {
+			if _, err := w.Write([]byte{0}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package sndfile
+
+import (
+	"encoding/binary"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// damage applies fn to the bytes of the file src and writes the result to dst.
+func damage(t *testing.T, dst, src string, fn func(b []byte, c *chunkFile) []byte) {
+	f, err := os.Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := scanChunks(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(dst, fn(b, c), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRepair(t *testing.T) {
+	want := writeRamp(t, "repairsrc.wav", Info{Samplerate: 8000, Channels: 2, Format: SF_FORMAT_WAV | SF_FORMAT_PCM_16}, 1000)
+	writeRamp(t, "repairsrc.aiff", Info{Samplerate: 8000, Channels: 2, Format: SF_FORMAT_AIFF | SF_FORMAT_PCM_16}, 1000)
+
+	cases := []struct {
+		name     string
+		src      string
+		fn       func(b []byte, c *chunkFile) []byte
+		problems HeaderProblem
+	}{
+		{"intact", "repairsrc.wav", func(b []byte, c *chunkFile) []byte { return b }, 0},
+		{"zero data size", "repairsrc.wav", func(b []byte, c *chunkFile) []byte {
+			binary.LittleEndian.PutUint32(b[c.find("data").pos+4:], 0)
+			return b
+		}, MissingDataSize},
+		{"truncated", "repairsrc.wav", func(b []byte, c *chunkFile) []byte {
+			return b[:c.find("data").data+900*4+3]
+		}, BadDataSize | PartialFrame | BadContainerSize},
+		{"trailing garbage", "repairsrc.wav", func(b []byte, c *chunkFile) []byte {
+			return append(b, 0, 0, 0, 0, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9)
+		}, TrailingGarbage},
+		{"aiff sizes", "repairsrc.aiff", func(b []byte, c *chunkFile) []byte {
+			binary.BigEndian.PutUint32(b[4:], 12)
+			binary.BigEndian.PutUint32(b[c.find("COMM").data+2:], 7)
+			binary.BigEndian.PutUint32(b[c.find("SSND").pos+4:], 0xFFFFFFF0)
+			return b
+		}, BadDataSize | BadContainerSize | BadFrameCount},
+	}
+	for _, c := range cases {
+		damage(t, "repairbroken", c.src, c.fn)
+		report, err := Repair("repairbroken", &RepairOptions{DryRun: true})
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if report.Problems != c.problems || report.Written {
+			t.Errorf("%s: dry run found %v, expected %v", c.name, report.Problems, c.problems)
+		}
+		report, err = Repair("repairbroken", &RepairOptions{Output: "repairfixed"})
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if report.Written != (c.problems != 0) {
+			t.Errorf("%s: written %v", c.name, report.Written)
+		}
+		if !report.Written {
+			continue
+		}
+		frames := 1000
+		if c.problems&PartialFrame != 0 {
+			frames = 900
+		}
+		if report.Frames != int64(frames) {
+			t.Errorf("%s: repaired file has %d frames, expected %d", c.name, report.Frames, frames)
+		}
+		if got := readAllInt16(t, "repairfixed"); !reflect.DeepEqual(got, want[:2*frames]) {
+			t.Errorf("%s: repaired audio differs", c.name)
+		}
+		if r, err := Repair("repairfixed", &RepairOptions{DryRun: true}); err != nil || r.Problems != 0 {
+			t.Errorf("%s: repaired file still has problems %v %v", c.name, r.Problems, err)
+		}
+		var i Info
+		f, err := Open("repairfixed", Read, &i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := f.GetString(Title); s != "ramp" {
+			t.Errorf("%s: title chunk lost, got %q", c.name, s)
+		}
+		f.Close()
+	}
+
+	// an odd sized data chunk followed by the strings, with the pad byte between them missing
+	i := Info{Samplerate: 8000, Channels: 1, Format: SF_FORMAT_WAV | SF_FORMAT_PCM_U8}
+	f, err := Open("repairsrc.wav", Write, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteItems(make([]int16, 101))
+	f.SetString("odd", Comment)
+	f.Close()
+	damage(t, "repairbroken", "repairsrc.wav", func(b []byte, c *chunkFile) []byte {
+		d := c.find("data")
+		end := d.data + d.size
+		return append(b[:end:end], b[end+1:]...)
+	})
+	if err = os.Chmod("repairbroken", 0604); err != nil {
+		t.Fatal(err)
+	}
+	report, err := Repair("repairbroken", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st, err := os.Stat("repairbroken"); err != nil {
+		t.Error(err)
+	} else if st.Mode().Perm() != 0604 {
+		t.Errorf("repair in place changed the file mode to %v", st.Mode())
+	}
+	if report.Problems != BadPadding {
+		t.Errorf("expected a padding problem, got %v", report.Problems)
+	}
+	f, err = Open("repairbroken", Read, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if i.Frames != 101 || f.GetString(Comment) != "odd" {
+		t.Errorf("repaired file has %d frames and comment %q", i.Frames, f.GetString(Comment))
+	}
+}
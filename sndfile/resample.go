@@ -0,0 +1,203 @@
+package sndfile
+
+import (
+	"errors"
+	"math"
+)
+
+// ResampleQuality trades the accuracy of a Resampler against its speed.
+type ResampleQuality int
+
+const (
+	ResampleFast   ResampleQuality = iota // 8 zero crossings, passband to 85% of Nyquist
+	ResampleMedium                        // 16 zero crossings, passband to 92% of Nyquist
+	ResampleBest                          // 32 zero crossings, passband to 96% of Nyquist
+)
+
+var resampleParams = []struct {
+	zeroCrossings int
+	beta          float64 // Kaiser window shape
+	rolloff       float64 // cutoff as a fraction of the lower Nyquist frequency
+	phases        int     // kernel table entries per input sample
+}{
+	{8, 6, 0.85, 128},
+	{16, 8, 0.92, 256},
+	{32, 10, 0.96, 512},
+}
+
+// A Resampler reads audio from a File at a different sample rate. It is a bandlimited interpolator: each output frame is the input convolved with a Kaiser windowed sinc centred on the output frame's time, low passed below the lower of the two Nyquist frequencies. Any pair of rates can be used; the position in the input is kept as an exact fraction, so it doesn't drift however long the file.
+type Resampler struct {
+	Format Info // the output format: the source's, with the new sample rate and number of frames
+
+	read     func([]float64) (int64, error)
+	channels int
+	inRate   int64
+	outRate  int64
+	half     int       // kernel half width in input frames
+	phases   int       // table entries per input frame
+	table    []float64 // the kernel for x >= 0
+	weights  []float64
+	in       []float64 // interleaved input frames, those from off on beginning at frame inStart
+	off      int       // samples at the front of in that no output frame needs any more
+	inStart  int64
+	eof      bool
+	pos      int64 // output frames produced
+	total    int64 // output frames to produce, -1 until known
+	rd       []float64
+}
+
+// NewResampler returns a Resampler reading from src, which must be open for reading, at rate frames per second. Samples are returned as float64, normalised as src is set to normalise them.
+func NewResampler(src *File, rate int, quality ResampleQuality) (*Resampler, error) {
+	return newResampler(func(b []float64) (int64, error) { return src.ReadFrames(b) }, src.Format, rate, quality)
+}
+
+// newResampler resamples audio of the given format supplied by read, so that it can follow other stages.
+func newResampler(read func([]float64) (int64, error), from Info, rate int, quality ResampleQuality) (*Resampler, error) {
+	if rate <= 0 || from.Samplerate <= 0 {
+		return nil, errors.New("NewResampler: bad sample rate")
+	}
+	if from.Channels <= 0 {
+		return nil, errors.New("NewResampler: no channels")
+	}
+	if quality < ResampleFast || quality > ResampleBest {
+		return nil, errors.New("NewResampler: bad quality")
+	}
+	p := resampleParams[quality]
+	r := &Resampler{
+		read:     read,
+		channels: int(from.Channels),
+		inRate:   int64(from.Samplerate),
+		outRate:  int64(rate),
+		phases:   p.phases,
+		total:    -1,
+	}
+	r.Format = from
+	r.Format.Samplerate = int32(rate)
+	r.Format.Frames = -1
+	if from.Frames >= 0 {
+		r.total = (from.Frames*r.outRate + r.inRate - 1) / r.inRate
+		r.Format.Frames = r.total
+	}
+
+	// when going down in rate the cutoff drops and the kernel widens to match
+	cutoff := p.rolloff
+	if r.outRate < r.inRate {
+		cutoff *= float64(r.outRate) / float64(r.inRate)
+	}
+	r.half = int(math.Ceil(float64(p.zeroCrossings) / cutoff))
+	r.table = make([]float64, r.half*r.phases+2)
+	i0beta := besselI0(p.beta)
+	for j := 0; j <= r.half*r.phases; j++ {
+		x := float64(j) / float64(r.phases)
+		u := x / float64(r.half)
+		w := besselI0(p.beta*math.Sqrt(1-u*u)) / i0beta
+		r.table[j] = cutoff * sinc(cutoff*x) * w
+	}
+	r.weights = make([]float64, 2*r.half)
+	r.rd = make([]float64, 4096*r.channels)
+	return r, nil
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// besselI0 is the zeroth order modified Bessel function of the first kind, by its power series.
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; term > sum*1e-12; k++ {
+		term *= (x / 2) * (x / 2) / float64(k*k)
+		sum += term
+	}
+	return sum
+}
+
+// kernel returns the filter at x input frames from its centre.
+func (r *Resampler) kernel(x float64) float64 {
+	p := math.Abs(x) * float64(r.phases)
+	j := int(p)
+	if j >= r.half*r.phases {
+		return 0
+	}
+	f := p - float64(j)
+	return r.table[j] + f*(r.table[j+1]-r.table[j])
+}
+
+// fill reads from the source until frame last is buffered or the source runs out.
+func (r *Resampler) fill(last int64) error {
+	for !r.eof && r.inStart+int64((len(r.in)-r.off)/r.channels) <= last {
+		n, err := r.read(r.rd)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			r.eof = true
+			if r.total < 0 {
+				end := r.inStart + int64((len(r.in)-r.off)/r.channels)
+				r.total = (end*r.outRate + r.inRate - 1) / r.inRate
+			}
+			break
+		}
+		// moving the frames still needed to the front only once a read block's worth has been dropped keeps the copying down to about once per block
+		if r.off >= len(r.rd) {
+			r.in = r.in[:copy(r.in, r.in[r.off:])]
+			r.off = 0
+		}
+		r.in = append(r.in, r.rd[:int(n)*r.channels]...)
+	}
+	return nil
+}
+
+// ReadFrames fills out with resampled frames and returns the number of frames read, which is only less than len(out) divided by the number of channels at the end of the audio.
+func (r *Resampler) ReadFrames(out []float64) (read int64, err error) {
+	want := int64(len(out) / r.channels)
+	for read < want {
+		if r.total >= 0 && r.pos >= r.total {
+			break
+		}
+		// the output frame falls at input time ti + frac
+		num := r.pos * r.inRate
+		ti := num / r.outRate
+		frac := float64(num%r.outRate) / float64(r.outRate)
+		first := ti - int64(r.half) + 1
+		if err = r.fill(ti + int64(r.half)); err != nil {
+			return
+		}
+		if r.total >= 0 && r.pos >= r.total {
+			break
+		}
+
+		// drop input that no later output frame needs
+		if drop := first - r.inStart; drop > 0 {
+			if r.off += int(drop) * r.channels; r.off >= len(r.in) {
+				r.in, r.off = r.in[:0], 0
+			}
+			r.inStart = first
+		}
+
+		for m := range r.weights {
+			r.weights[m] = r.kernel(frac + float64(r.half-1-m))
+		}
+		o := out[int(read)*r.channels : int(read+1)*r.channels]
+		for c := range o {
+			o[c] = 0
+		}
+		buffered := r.inStart + int64((len(r.in)-r.off)/r.channels)
+		for m, w := range r.weights {
+			i := first + int64(m)
+			if i < r.inStart || i >= buffered {
+				continue // before the start or past the end of the input, which count as silence
+			}
+			s := r.in[r.off+int(i-r.inStart)*r.channels:]
+			for c := range o {
+				o[c] += w * s[c]
+			}
+		}
+		read++
+		r.pos++
+	}
+	return
+}
@@ -0,0 +1,130 @@
+package sndfile
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func writeSine(t *testing.T, name string, rate int32, freq float64, frames int) {
+	i := Info{Samplerate: rate, Channels: 2, Format: SF_FORMAT_WAV | SF_FORMAT_FLOAT}
+	f, err := Open(name, Write, &i)
+	if err != nil {
+		t.Fatal("couldn't open file to write", err)
+	}
+	out := make([]float64, 2*frames)
+	for n := 0; n < frames; n++ {
+		s := 0.5 * math.Sin(2*math.Pi*freq*float64(n)/float64(rate))
+		out[2*n], out[2*n+1] = s, -s
+	}
+	if _, err = f.WriteFrames(out); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+}
+
+func TestResampler(t *testing.T) {
+	writeSine(t, "resamplesrc.wav", 44100, 1000, 44100)
+	for _, q := range []ResampleQuality{ResampleFast, ResampleMedium, ResampleBest} {
+		var i Info
+		f, err := Open("resamplesrc.wav", Read, &i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := NewResampler(f, 48000, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Format.Frames != 48000 || r.Format.Samplerate != 48000 {
+			t.Errorf("unexpected output format %v", r.Format)
+		}
+		out := make([]float64, 2*48000+200)
+		var got int64
+		// odd block sizes to check nothing is lost between reads
+		for got < 48000 {
+			end := (got + 777) * 2
+			if end > int64(len(out)) {
+				end = int64(len(out))
+			}
+			n, err := r.ReadFrames(out[got*2 : end])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n == 0 {
+				break
+			}
+			got += n
+		}
+		f.Close()
+		if got != 48000 {
+			t.Errorf("quality %d: got %d frames, expected 48000", q, got)
+		}
+		// away from the ends the output should be the same sine sampled at the new rate
+		var maxErr float64
+		for n := 1000; n < 47000; n++ {
+			want := 0.5 * math.Sin(2*math.Pi*1000*float64(n)/48000)
+			maxErr = math.Max(maxErr, math.Abs(out[2*n]-want))
+			maxErr = math.Max(maxErr, math.Abs(out[2*n+1]+want))
+		}
+		if limit := []float64{1e-3, 1e-4, 1e-5}[q]; maxErr > limit {
+			t.Errorf("quality %d: error %g exceeds %g", q, maxErr, limit)
+		}
+	}
+}
+
+func TestResamplerAntialias(t *testing.T) {
+	// 10 kHz is above the Nyquist frequency of the output, so it should be filtered out
+	writeSine(t, "resamplesrc.wav", 48000, 10000, 48000)
+	var i Info
+	f, err := Open("resamplesrc.wav", Read, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := NewResampler(f, 8000, ResampleMedium)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make([]float64, 2*8000)
+	n, _ := r.ReadFrames(out)
+	if n != 8000 {
+		t.Fatalf("got %d frames, expected 8000", n)
+	}
+	var sum float64
+	for _, s := range out[2*500 : 2*7500] {
+		sum += s * s
+	}
+	if rms := math.Sqrt(sum / (2 * 7000)); rms > 1e-3 {
+		t.Errorf("aliased tone left at rms %g", rms)
+	}
+}
+
+func TestConvertResample(t *testing.T) {
+	writeSine(t, "resamplesrc.wav", 44100, 440, 4410)
+	err := Convert(context.Background(), "resamplesrc.wav", "resampledst.wav", Info{Samplerate: 48000, Format: SF_FORMAT_WAV | SF_FORMAT_PCM_24}, nil)
+	if err != nil {
+		t.Fatal("convert failed", err)
+	}
+	var i Info
+	f, err := Open("resampledst.wav", Read, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if i.Samplerate != 48000 || i.Frames != 4800 {
+		t.Errorf("expected 4800 frames at 48000 Hz, got %d at %d", i.Frames, i.Samplerate)
+	}
+}
+
+func TestResamplerBadRate(t *testing.T) {
+	read := func(b []float64) (int64, error) { return 0, nil }
+	if _, err := newResampler(read, Info{Channels: 1}, 48000, ResampleMedium); err == nil {
+		t.Error("expected audio with no sample rate to be refused")
+	}
+	if _, err := newResampler(read, Info{Channels: 1, Samplerate: 44100}, 0, ResampleMedium); err == nil {
+		t.Error("expected a zero output rate to be refused")
+	}
+	if _, err := newResampler(read, Info{Samplerate: 44100}, 48000, ResampleMedium); err == nil {
+		t.Error("expected audio with no channels to be refused")
+	}
+}
@@ -0,0 +1,66 @@
+package sndfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// RIFF sizes are 32 bits, so plain WAV can't hold more than 4 GiB. RF64 replaces them with a ds64 chunk of 64 bit sizes, but fewer programs can read it.
+
+// OpenLargeWAV opens name for writing as a WAV file that may grow past 4 GiB. The file is written as RF64, and libsndfile writes a plain WAV header (with a WAVEX fmt chunk) instead when it is closed if the file turned out small enough. In effect the file is promoted to RF64 only when it has to be, which is decided on Close, as that is when the header is written.
+
+// info.Format may give WAV, WAVEX or RF64 as the major format; the subformat is kept. The other info fields are as for Open.
+func OpenLargeWAV(name string, info *Info) (*File, error) {
+	if info == nil {
+		return nil, errors.New("nil pointer passed to open")
+	}
+	if err := largeWAVFormat(info); err != nil {
+		return nil, err
+	}
+	f, err := Open(name, Write, info)
+	if err != nil {
+		return nil, err
+	}
+	if err = enableDowngrade(f); err != nil {
+		os.Remove(name)
+		return nil, err
+	}
+	return f, nil
+}
+
+// OpenVirtualLargeWAV is OpenLargeWAV for a virtual file, see OpenVirtual. The virtual file must support seeking back to rewrite the header.
+func OpenVirtualLargeWAV(v VirtualIo, info *Info) (*File, error) {
+	if info == nil {
+		return nil, errors.New("nil pointer passed to open")
+	}
+	if err := largeWAVFormat(info); err != nil {
+		return nil, err
+	}
+	f, err := OpenVirtual(v, Write, info)
+	if err != nil {
+		return nil, err
+	}
+	if err = enableDowngrade(f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func largeWAVFormat(info *Info) error {
+	switch info.Format & SF_FORMAT_TYPEMASK {
+	case SF_FORMAT_WAV, SF_FORMAT_WAVEX, SF_FORMAT_RF64:
+	default:
+		return fmt.Errorf("OpenLargeWAV: major format %#x isn't WAV", info.Format&SF_FORMAT_TYPEMASK)
+	}
+	info.Format = SF_FORMAT_RF64 | info.Format&(SF_FORMAT_SUBMASK|SF_FORMAT_ENDMASK)
+	return nil
+}
+
+func enableDowngrade(f *File) error {
+	if !f.SetRF64AutoDowngrade(true) {
+		f.Close()
+		return errors.New("OpenLargeWAV: libsndfile refused to enable RF64 downgrading")
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package sndfile
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+// sparseFile skips over blocks of zeros instead of writing them, so that multi gigabyte test files take up next to no disk and time.
+type sparseFile struct {
+	f        *os.File
+	pos, end int64
+}
+
+func sparseFileIo(f *os.File) (v VirtualIo) {
+	zeros := make([]byte, 1<<20)
+	v.UserData = &sparseFile{f: f}
+	v.GetLength = func(ud interface{}) int64 {
+		return ud.(*sparseFile).end
+	}
+	v.Seek = func(offset int64, w Whence, ud interface{}) int64 {
+		s := ud.(*sparseFile)
+		switch w {
+		case Set:
+			s.pos = offset
+		case Current:
+			s.pos += offset
+		case End:
+			s.pos = s.end + offset
+		}
+		return s.pos
+	}
+	v.Read = func(b []byte, ud interface{}) int64 {
+		s := ud.(*sparseFile)
+		if s.pos+int64(len(b)) > s.end {
+			b = b[:s.end-s.pos]
+		}
+		n, _ := s.f.ReadAt(b, s.pos)
+		for i := n; i < len(b); i++ {
+			b[i] = 0
+		}
+		s.pos += int64(len(b))
+		return int64(len(b))
+	}
+	v.Write = func(b []byte, ud interface{}) int64 {
+		s := ud.(*sparseFile)
+		if len(b) > len(zeros) || !bytes.Equal(b, zeros[:len(b)]) {
+			if _, err := s.f.WriteAt(b, s.pos); err != nil {
+				return 0
+			}
+		}
+		s.pos += int64(len(b))
+		if s.pos > s.end {
+			s.end = s.pos
+		}
+		return int64(len(b))
+	}
+	v.Tell = func(ud interface{}) int64 {
+		return ud.(*sparseFile).pos
+	}
+	return
+}
+
+func TestLargeWAVDowngrade(t *testing.T) {
+	i := Info{Samplerate: 48000, Channels: 2, Format: SF_FORMAT_WAV | SF_FORMAT_FLOAT}
+	f, err := OpenLargeWAV("largesmall.wav", &i)
+	if err != nil {
+		t.Fatal("couldn't open file to write", err)
+	}
+	f.WriteFrames(make([]float32, 2*4800))
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f, err = Open("largesmall.wav", Read, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	// the RF64 writer always uses the extensible fmt chunk, so the downgraded file reads back as WAVEX
+	if i.Format&SF_FORMAT_TYPEMASK != SF_FORMAT_WAVEX || i.Frames != 4800 {
+		t.Errorf("expected a 4800 frame WAVEX, got format %#x with %d frames", i.Format, i.Frames)
+	}
+
+	i.Format = SF_FORMAT_AIFF | SF_FORMAT_FLOAT
+	if _, err = OpenLargeWAV("largesmall.wav", &i); err == nil {
+		t.Error("OpenLargeWAV accepted AIFF")
+	}
+}
+
+func TestLargeWAVPromote(t *testing.T) {
+	if testing.Short() {
+		t.Skip("writes a 4 GiB sparse file")
+	}
+	out, err := os.Create("largebig.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("largebig.wav")
+	v := sparseFileIo(out)
+	i := Info{Samplerate: 48000, Channels: 2, Format: SF_FORMAT_WAV | SF_FORMAT_FLOAT}
+	f, err := OpenVirtualLargeWAV(v, &i)
+	if err != nil {
+		t.Fatal("couldn't open file to write", err)
+	}
+	f.SetAddPeakChunk(false)
+
+	// 4 GiB of float stereo is 1<<29 frames, so this ends just past the RIFF limit
+	const block = 1 << 16
+	const frames = 1<<29 + block
+	buf := make([]float32, 2*block)
+	for n := 0; n < frames/block-1; n++ {
+		if w, err := f.WriteFrames(buf); err != nil || w != block {
+			t.Fatal("write failed", w, err)
+		}
+	}
+	for n := range buf {
+		buf[n] = float32(n%100) / 100
+	}
+	if _, err = f.WriteFrames(buf); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = out.Truncate(v.UserData.(*sparseFile).end); err != nil {
+		t.Fatal(err)
+	}
+	out.Close()
+
+	f, err = Open("largebig.wav", Read, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if i.Format&SF_FORMAT_TYPEMASK != SF_FORMAT_RF64 || i.Frames != frames {
+		t.Fatalf("expected a %d frame RF64 file, got format %#x with %d frames", frames, i.Format, i.Frames)
+	}
+	if _, err = f.Seek(frames-block, Set); err != nil {
+		t.Fatal(err)
+	}
+	in := make([]float32, len(buf))
+	if n, err := f.ReadFrames(in); err != nil || n != block {
+		t.Fatal("couldn't read the end of the file", n, err)
+	}
+	for n := range in {
+		if in[n] != buf[n] {
+			t.Fatalf("sample %d past 4 GiB is %v, expected %v", n, in[n], buf[n])
+		}
+	}
+}
+
+func TestLargeWAVSparse(t *testing.T) {
+	if testing.Short() {
+		t.Skip("writes a 4 GiB sparse file")
+	}
+	defer os.Remove("largesparse.wav")
+	i := Info{Samplerate: 48000, Channels: 2, Format: SF_FORMAT_WAV | SF_FORMAT_FLOAT}
+	f, err := OpenLargeWAV("largesparse.wav", &i)
+	if err != nil {
+		t.Fatal("couldn't open file to write", err)
+	}
+	f.SetAddPeakChunk(false)
+
+	// seeking past the end leaves a hole in the file, so only the last block takes up disk
+	const block = 1 << 16
+	const frames = 1<<29 + block
+	if _, err = f.Seek(frames-block, Set); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]float32, 2*block)
+	for n := range buf {
+		buf[n] = float32(n%100) / 100
+	}
+	if w, err := f.WriteFrames(buf); err != nil || w != block {
+		t.Fatal("write failed", w, err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err = Open("largesparse.wav", Read, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if i.Format&SF_FORMAT_TYPEMASK != SF_FORMAT_RF64 || i.Frames != frames {
+		t.Fatalf("expected a %d frame RF64 file, got format %#x with %d frames", frames, i.Format, i.Frames)
+	}
+	if _, err = f.Seek(frames-block, Set); err != nil {
+		t.Fatal(err)
+	}
+	in := make([]float32, len(buf))
+	if n, err := f.ReadFrames(in); err != nil || n != block {
+		t.Fatal("couldn't read the end of the file", n, err)
+	}
+	for n := range in {
+		if in[n] != buf[n] {
+			t.Fatalf("sample %d past 4 GiB is %v, expected %v", n, in[n], buf[n])
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package sndfile
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// RotateOptions controls where a RotatingWriter starts a new file. Whichever limit is reached first applies; at least one must be set.
+type RotateOptions struct {
+	MaxFrames   int64         // frames per segment
+	MaxDuration time.Duration // length of each segment
+	MaxBytes    int64         // bytes of audio data per segment, not counting the header. Only for formats with a fixed sample size.
+
+	Start      time.Time // wall clock time of the first frame, time.Now() if zero. Segment times are counted from this in frames, so they don't drift.
+	TimeLayout string    // layout for {time} in the template, "20060102-150405" if empty
+
+	// Broadcast, if not nil, is written to every segment whose format can hold it, with the origination date and time and the time reference filled in for the segment.
+	Broadcast *BroadcastInfo
+
+	// OnSegment is called after each segment has been closed.
+	OnSegment func(Segment)
+}
+
+// A Segment is one of the files written by a RotatingWriter.
+type Segment struct {
+	Path       string
+	Index      int       // 0 for the first segment
+	Start      time.Time // time of the first frame
+	StartFrame int64     // position of the first frame in the whole capture
+	Frames     int64
+}
+
+// A RotatingWriter writes one long capture as a series of files. Frames are never dropped or repeated at the boundaries: the first frame of each segment directly follows the last frame of the one before.
+type RotatingWriter struct {
+	template string
+	info     Info
+	opts     RotateOptions
+	limit    int64
+	f        *File
+	seg      Segment
+	next     int
+	frames   int64
+}
+
+// OpenRotating starts a capture in the format given by info. Segment file names are made from template by replacing {seq} with the segment index, zero padded to four digits, and {time} with the start time of the segment; the template must contain at least one of these. A Date string with the segment start time is set on every segment whose format can hold it.
+func OpenRotating(template string, info Info, opts RotateOptions) (w *RotatingWriter, err error) {
+	if !strings.Contains(template, "{seq}") && !strings.Contains(template, "{time}") {
+		return nil, errors.New("OpenRotating: template needs {seq} or {time}")
+	}
+	if info.Samplerate <= 0 || info.Channels <= 0 {
+		return nil, errors.New("OpenRotating: sample rate and channels must be set")
+	}
+	w = &RotatingWriter{template: template, info: info, opts: opts}
+	if w.opts.Start.IsZero() {
+		w.opts.Start = time.Now()
+	}
+	if w.opts.TimeLayout == "" {
+		w.opts.TimeLayout = "20060102-150405"
+	}
+	rate := int64(info.Samplerate)
+	if opts.MaxFrames > 0 {
+		w.limit = opts.MaxFrames
+	}
+	if opts.MaxDuration > 0 {
+		if l := int64(opts.MaxDuration/time.Second)*rate + int64(opts.MaxDuration%time.Second)*rate/int64(time.Second); w.limit == 0 || l < w.limit {
+			w.limit = l
+		}
+	}
+	if opts.MaxBytes > 0 {
+		width := rawSampleWidth(info.Format & SF_FORMAT_SUBMASK)
+		if width <= 0 {
+			return nil, fmt.Errorf("OpenRotating: MaxBytes can't be used with format %#x", info.Format)
+		}
+		if l := opts.MaxBytes / (int64(width) * int64(info.Channels)); w.limit == 0 || l < w.limit {
+			w.limit = l
+		}
+	}
+	if w.limit <= 0 {
+		return nil, errors.New("OpenRotating: no segment limit, or limit shorter than a frame")
+	}
+	if err = w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// frameTime returns the wall clock time of a frame of the capture.
+func (w *RotatingWriter) frameTime(frame int64) time.Time {
+	rate := int64(w.info.Samplerate)
+	return w.opts.Start.Add(time.Duration(frame/rate)*time.Second + time.Duration(frame%rate)*time.Second/time.Duration(rate))
+}
+
+func (w *RotatingWriter) open() error {
+	start := w.frameTime(w.frames)
+	name := strings.NewReplacer("{seq}", fmt.Sprintf("%04d", w.next), "{time}", start.Format(w.opts.TimeLayout)).Replace(w.template)
+	info := w.info
+	f, err := Open(name, Write, &info)
+	if err != nil {
+		return err
+	}
+	if stringSupported(info, Date) {
+		if err = f.SetString(start.Format(time.RFC3339), Date); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if w.opts.Broadcast != nil && MetadataSupport(info)&CopyBroadcast != 0 {
+		bi := *w.opts.Broadcast
+		bi.Origination_date = start.Format("2006-01-02")
+		bi.Origination_time = start.Format("15:04:05")
+		// the time reference counts samples since midnight
+		y, m, d := start.Date()
+		since := start.Sub(time.Date(y, m, d, 0, 0, 0, 0, start.Location()))
+		ref := int64(since/time.Second)*int64(info.Samplerate) + int64(since%time.Second)*int64(info.Samplerate)/int64(time.Second)
+		bi.Time_reference_low = uint32(ref)
+		bi.Time_reference_high = uint32(ref >> 32)
+		if err = f.SetBroadcastInfo(&bi); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	w.f = f
+	w.seg = Segment{Path: name, Index: w.next, Start: start, StartFrame: w.frames}
+	w.next++
+	return nil
+}
+
+// finish closes the current segment and calls the hook.
+func (w *RotatingWriter) finish() error {
+	err := w.f.Close()
+	w.f = nil
+	if err != nil {
+		return err
+	}
+	if w.opts.OnSegment != nil {
+		w.opts.OnSegment(w.seg)
+	}
+	return nil
+}
+
+// Write writes frames from items, which is a slice of one of the types File.WriteFrames accepts, starting new segments as needed.
+func (w *RotatingWriter) Write(items interface{}) (written int64, err error) {
+	v := reflect.ValueOf(items)
+	if v.Kind() != reflect.Slice {
+		return 0, errors.New("RotatingWriter: Write needs a slice")
+	}
+	channels := int64(w.info.Channels)
+	total := int64(v.Len()) / channels
+	for written < total {
+		if w.f == nil {
+			if err = w.open(); err != nil {
+				return
+			}
+		}
+		n := total - written
+		if room := w.limit - w.seg.Frames; n > room {
+			n = room
+		}
+		var c int64
+		c, err = w.f.WriteFrames(v.Slice(int(written*channels), int((written+n)*channels)).Interface())
+		written += c
+		w.seg.Frames += c
+		w.frames += c
+		if err != nil {
+			return
+		}
+		if c != n {
+			return written, errors.New("RotatingWriter: short write")
+		}
+		if w.seg.Frames == w.limit {
+			if err = w.finish(); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// Frames returns the number of frames written over all segments.
+func (w *RotatingWriter) Frames() int64 {
+	return w.frames
+}
+
+// Close closes the current segment. A capture that ended exactly on a segment boundary has no current segment, so no empty file is left at the end.
+func (w *RotatingWriter) Close() error {
+	if w.f == nil {
+		return nil
+	}
+	return w.finish()
+}
@@ -0,0 +1,85 @@
+package sndfile
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRotatingWriter(t *testing.T) {
+	start := time.Date(2020, 3, 1, 23, 59, 59, 0, time.UTC)
+	var segs []Segment
+	opts := RotateOptions{
+		MaxDuration: 1500 * time.Millisecond,
+		MaxBytes:    2 * 2 * 1000,
+		Start:       start,
+		Broadcast:   &BroadcastInfo{Description: "logger"},
+		OnSegment:   func(s Segment) { segs = append(segs, s) },
+	}
+	i := Info{Samplerate: 1000, Channels: 2, Format: SF_FORMAT_WAV | SF_FORMAT_PCM_16}
+	w, err := OpenRotating("rotate-{seq}-{time}.wav", i, opts)
+	if err != nil {
+		t.Fatal("couldn't open rotating writer", err)
+	}
+	want := make([]int16, 2*2500)
+	for n := range want {
+		want[n] = int16(n)
+	}
+	// blocks that don't line up with the 1000 frame segments set by MaxBytes
+	for n := 0; n < len(want); {
+		end := n + 2*333
+		if end > len(want) {
+			end = len(want)
+		}
+		if _, err = w.Write(want[n:end]); err != nil {
+			t.Fatal("write failed", err)
+		}
+		n = end
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if w.Frames() != 2500 || len(segs) != 3 {
+		t.Fatalf("expected 2500 frames in 3 segments, got %d in %v", w.Frames(), segs)
+	}
+
+	var got []int16
+	for n, s := range segs {
+		ws := Segment{Index: n, Start: start.Add(time.Duration(n) * time.Second), StartFrame: int64(n) * 1000, Frames: 1000}
+		ws.Path = "rotate-000" + string(rune('0'+n)) + "-" + ws.Start.Format("20060102-150405") + ".wav"
+		if n == 2 {
+			ws.Frames = 500
+		}
+		if !reflect.DeepEqual(s, ws) {
+			t.Errorf("segment %d is %v, expected %v", n, s, ws)
+		}
+		got = append(got, readAllInt16(t, s.Path)...)
+
+		var si Info
+		f, err := Open(s.Path, Read, &si)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := f.GetString(Date); d != ws.Start.Format(time.RFC3339) {
+			t.Errorf("segment %d has date %q", n, d)
+		}
+		bi, ok := f.GetBroadcastInfo()
+		f.Close()
+		if !ok {
+			t.Errorf("segment %d has no broadcast info", n)
+			continue
+		}
+		// the first segment starts a second before midnight
+		ref := []uint32{86399 * 1000, 0, 1000}[n]
+		if bi.Description != "logger" || bi.Time_reference_low != ref || bi.Origination_date != ws.Start.Format("2006-01-02") {
+			t.Errorf("segment %d has broadcast info %+v", n, bi)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Error("frames were dropped or repeated at segment boundaries")
+	}
+
+	if _, err = OpenRotating("rotate.wav", i, opts); err == nil {
+		t.Error("template without {seq} or {time} was accepted")
+	}
+}
@@ -0,0 +1,251 @@
+package sndfile
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"time"
+)
+
+// A FrameRange is a span of frames in a file, from Start up to but not including End.
+type FrameRange struct {
+	Start, End int64
+}
+
+// Frames returns the length of the range.
+func (r FrameRange) Frames() int64 {
+	return r.End - r.Start
+}
+
+// DetectSilence reads the whole of f, which must be open for reading, and returns the runs of at least minDuration in which every sample of every channel is below thresholdDB, in dBFS. The ranges are in order and don't overlap. The read position is restored afterwards.
+func DetectSilence(f *File, thresholdDB float64, minDuration time.Duration) ([]FrameRange, error) {
+	channels := int(f.Format.Channels)
+	threshold := math.Pow(10, thresholdDB/20)
+	min := int64(math.Round(minDuration.Seconds() * float64(f.Format.Samplerate)))
+	if min < 1 {
+		min = 1
+	}
+
+	var ranges []FrameRange
+	var n, start int64
+	quiet := false
+	end := func() {
+		if quiet && n-start >= min {
+			ranges = append(ranges, FrameRange{start, n})
+		}
+		quiet = false
+	}
+	err := f.readAll(context.Background(), func(b []float64) {
+		for i := 0; i < len(b); i += channels {
+			loud := false
+			for _, s := range b[i : i+channels] {
+				if math.Abs(s) >= threshold {
+					loud = true
+					break
+				}
+			}
+			if loud {
+				end()
+			} else if !quiet {
+				quiet, start = true, n
+			}
+			n++
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	end()
+	return ranges, nil
+}
+
+// TrimOptions controls how Trim cuts the silence from the ends of a file.
+type TrimOptions struct {
+	ThresholdDB float64       // level in dBFS below which audio counts as silence; a threshold at full scale would take in everything, so zero means the default of -60 and positive levels are an error
+	Padding     time.Duration // silence to keep before the first and after the last sound, as far as the source has it
+	FadeIn      time.Duration // length of a linear fade at the start of the trimmed audio
+	FadeOut     time.Duration // length of a linear fade at its end
+}
+
+// Trim writes a copy of the file at src to dst, in the same format, with the silence at its start and end removed. Metadata is carried over, with cue points that fall within the trimmed audio moved to match it and the time reference of the broadcast extension advanced past the removed frames. Audio is copied as int32 between integer formats, so that samples outside the fades are unchanged. It is an error for src to be silent throughout.
+//
+// If trimming fails, the partly written dst is removed.
+func Trim(src, dst string, opts TrimOptions) (err error) {
+	if opts.ThresholdDB > 0 {
+		return errors.New("Trim: threshold above full scale")
+	}
+	var si Info
+	in, err := Open(src, Read, &si)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if opts.ThresholdDB == 0 {
+		opts.ThresholdDB = -60
+	}
+	silence, err := DetectSilence(in, opts.ThresholdDB, 0)
+	if err != nil {
+		return err
+	}
+
+	r := FrameRange{0, si.Frames}
+	if len(silence) > 0 && silence[0].Start == 0 {
+		if silence[0].End == si.Frames {
+			return errors.New("Trim: source is silent")
+		}
+		r.Start = silence[0].End
+	}
+	if len(silence) > 0 && silence[len(silence)-1].End == si.Frames {
+		r.End = silence[len(silence)-1].Start
+	}
+	pad := durationFrames(opts.Padding, si.Samplerate)
+	r.Start = max64(r.Start-pad, 0)
+	r.End = min64(r.End+pad, si.Frames)
+
+	di := si
+	out, err := Open(dst, Write, &di)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(dst)
+		}
+	}()
+	if err = copyExcerptMetadata(out, in, r); err != nil {
+		return err
+	}
+	return copyRange(context.Background(), out, in, r, fadeGain(r.Frames(), durationFrames(opts.FadeIn, si.Samplerate), durationFrames(opts.FadeOut, si.Samplerate)))
+}
+
+func durationFrames(d time.Duration, samplerate int32) int64 {
+	return int64(math.Round(d.Seconds() * float64(samplerate)))
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// fadeGain returns the gain at each frame of a stretch of audio with linear fades of the given lengths at its start and end, or nil if there are none.
+func fadeGain(frames, in, out int64) func(n int64) float64 {
+	if in <= 0 && out <= 0 {
+		return nil
+	}
+	return func(n int64) float64 {
+		g := 1.0
+		if n < in {
+			g = float64(n) / float64(in)
+		}
+		if left := frames - 1 - n; left < out {
+			g = math.Min(g, float64(left)/float64(out))
+		}
+		return g
+	}
+}
+
+// copyExcerptMetadata copies the metadata of src to dst, which has had no audio written yet, for an excerpt of the frames in r. Cue points whose sample offset is within r are moved to their place in the excerpt and the others dropped, and the time reference of the broadcast extension is advanced to the start of r. Metadata that dst can't hold is skipped, as by CopyMetadata.
+func copyExcerptMetadata(dst, src *File, r FrameRange) error {
+	if _, err := CopyMetadata(dst, src, CopyAll&^(CopyBroadcast|CopyCues)); err != nil {
+		return err
+	}
+	support := MetadataSupport(dst.Format)
+	if bi, ok := src.GetBroadcastInfo(); ok && support&CopyBroadcast != 0 {
+		ref := (uint64(bi.Time_reference_high)<<32 | uint64(bi.Time_reference_low)) + uint64(r.Start)
+		bi.Time_reference_low = uint32(ref)
+		bi.Time_reference_high = uint32(ref >> 32)
+		if err := dst.SetBroadcastInfo(bi); err != nil {
+			return err
+		}
+	}
+	if cues, ok := src.GetCues(); ok && support&CopyCues != 0 {
+		var kept []CuePoint
+		for _, c := range cues {
+			// the sample offset is where the cue is; AIFF markers have no position
+			if o := int64(c.SampleOffset); o < r.Start || o >= r.End {
+				continue
+			}
+			c.SampleOffset -= uint32(r.Start)
+			if int64(c.Position) >= r.Start {
+				c.Position -= uint32(r.Start)
+			} else {
+				c.Position = c.SampleOffset
+			}
+			kept = append(kept, c)
+		}
+		if kept != nil {
+			return dst.SetCues(kept)
+		}
+	}
+	return nil
+}
+
+// copyRange copies the frames in r from src to dst, scaling frame n of the range by gain(n) if gain is not nil. Audio passes through int32 when both files hold integers, as in ConvertFile.
+func copyRange(ctx context.Context, dst, src *File, r FrameRange, gain func(n int64) float64) error {
+	if _, err := src.Seek(r.Start, Set); err != nil {
+		return err
+	}
+	channels := int(src.Format.Channels)
+	var buf interface{}
+	var ibuf []int32
+	var fbuf []float64
+	if isIntegerFormat(src.Format.Format) && isIntegerFormat(dst.Format.Format) {
+		ibuf = make([]int32, 4096*channels)
+		buf = ibuf
+	} else {
+		fbuf = make([]float64, 4096*channels)
+		buf = fbuf
+		defer src.SetDoubleNormalization(src.SetDoubleNormalization(true))
+	}
+
+	for done := int64(0); done < r.Frames(); {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		want := min64(4096, r.Frames()-done)
+		n, err := src.ReadFrames(sliceFrames(buf, int(want)*channels))
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return errors.New("copyRange: source ended early")
+		}
+		if gain != nil {
+			for i := 0; i < int(n); i++ {
+				g := gain(done + int64(i))
+				if g == 1 {
+					continue
+				}
+				for c := i * channels; c < (i+1)*channels; c++ {
+					if ibuf != nil {
+						ibuf[c] = int32(math.Round(float64(ibuf[c]) * g))
+					} else {
+						fbuf[c] *= g
+					}
+				}
+			}
+		}
+		w, err := dst.WriteFrames(sliceFrames(buf, int(n)*channels))
+		if err != nil {
+			return err
+		}
+		if w != n {
+			return errors.New("copyRange: short write")
+		}
+		done += n
+	}
+	return nil
+}
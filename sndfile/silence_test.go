@@ -0,0 +1,92 @@
+package sndfile
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrim(t *testing.T) {
+	// half a second of silence, a second of tone, a 300 ms gap, another second of tone and half a second of silence
+	a := make([]float64, 0, 2*158400)
+	a = append(a, make([]float64, 2*24000)...)
+	a = append(a, tone(48000, 2, -20, 0, 1)...)
+	a = append(a, make([]float64, 2*14400)...)
+	a = append(a, tone(48000, 2, -20, 1)...)
+	a = append(a, make([]float64, 2*24000)...)
+	i := Info{Samplerate: 48000, Channels: 2, Format: SF_FORMAT_WAV | SF_FORMAT_PCM_16}
+	writeFloats(t, "trimsrc.wav", i, a, func(f *File) {
+		f.SetBroadcastInfo(&BroadcastInfo{Description: "gaps", Time_reference_low: 1000})
+		f.SetCues([]CuePoint{{Index: 1, Position: 1000, FccChunk: 0x61746164, SampleOffset: 1000, Name: "early"}, {Index: 2, Position: 86400, FccChunk: 0x61746164, SampleOffset: 86400, Name: "gap"}, {Index: 3, Position: 30000, FccChunk: 0x61746164, SampleOffset: 500, Name: "before"}})
+	})
+
+	f, err := Open("trimsrc.wav", Read, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Seek(100, Set)
+	s, err := DetectSilence(f, -60, 200*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the first sample of each tone is 0, and counts as silence
+	want := []FrameRange{{0, 24001}, {72000, 86401}, {134400, 158400}}
+	if len(s) != len(want) {
+		t.Fatalf("silence at %v, expected %v", s, want)
+	}
+	for j := range s {
+		if s[j] != want[j] {
+			t.Errorf("silence at %v, expected %v", s, want)
+		}
+	}
+	if pos, _ := f.Seek(0, Current); pos != 100 {
+		t.Errorf("read position moved to %d", pos)
+	}
+	src := make([]int32, 2*i.Frames)
+	f.Seek(0, Set)
+	f.ReadFrames(src)
+	f.Close()
+
+	err = Trim("trimsrc.wav", "trimdst.wav", TrimOptions{Padding: 100 * time.Millisecond, FadeIn: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f, err = Open("trimdst.wav", Read, &i); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	const start, end = 24001 - 4800, 134400 + 4800
+	if i.Frames != end-start {
+		t.Fatalf("trimmed to %d frames, expected %d", i.Frames, end-start)
+	}
+	dst := make([]int32, 2*i.Frames)
+	f.ReadFrames(dst)
+	for n := 480; n < end-start; n++ {
+		for c := 0; c < 2; c++ {
+			if dst[2*n+c] != src[2*(start+n)+c] {
+				t.Fatalf("frame %d channel %d is %d, expected %d", n, c, dst[2*n+c], src[2*(start+n)+c])
+			}
+		}
+	}
+	if dst[0] != 0 {
+		t.Error("fade in doesn't start from silence")
+	}
+
+	bi, ok := f.GetBroadcastInfo()
+	if !ok || bi.Time_reference_low != 1000+start {
+		t.Errorf("broadcast extension %+v, expected time reference %d", bi, 1000+start)
+	}
+	cues, ok := f.GetCues()
+	// the third cue's position is in the excerpt but its sample offset is before it
+	if !ok || len(cues) != 1 || cues[0].Index != 2 || cues[0].Position != 86400-start || cues[0].SampleOffset != 86400-start {
+		t.Errorf("cues %+v, expected only the second at %d", cues, 86400-start)
+	}
+
+	// a silent file can't be trimmed
+	writeFloats(t, "trimsrc.wav", i, make([]float64, 2000), nil)
+	if err = Trim("trimsrc.wav", "trimsilent.wav", TrimOptions{}); err == nil {
+		t.Error("expected an error trimming silence")
+	}
+	if err = Trim("trimsrc.wav", "trimsilent.wav", TrimOptions{ThresholdDB: 6}); err == nil {
+		t.Error("expected an error for a threshold above full scale")
+	}
+}
@@ -13,6 +13,8 @@ import (
 	"os"
 	"reflect"
 	"runtime"
+	"strconv"
+	"strings"
 	"unsafe"
 )
 
@@ -24,6 +26,8 @@ type File struct {
 	fd      uintptr
 	closeFd bool
 	closed  bool
+	dither  *ditherer      // set by SetDither
+	meter   *LoudnessMeter // set by SetLoudnessMeter
 }
 
 // sErrorType represents a sndfile API error and grabs error description strings from the API.
@@ -166,7 +170,7 @@ func Open(name string, mode Mode, info *Info) (o *File, err error) {
 	ci := info.toCinfo()
 	o.s = C.sf_open(c, C.int(mode), ci)
 	if o.s == nil {
-		err = errors.New(C.GoString(C.sf_strerror(o.s)))
+		err = newOpenError(C.GoString(C.sf_strerror(o.s)), o)
 	}
 	*info = fromCinfo(ci)
 	o.Format = *info
@@ -187,7 +191,7 @@ func OpenFd(fd uintptr, mode Mode, info *Info, close_desc bool) (o *File, err er
 	ci := info.toCinfo()
 	o.s = C.sf_open_fd(C.int(fd), C.int(mode), ci, 0) // don't want libsndfile to close a Go file object from under us
 	if o.s == nil {
-		err = errors.New(C.GoString(C.sf_strerror(o.s)))
+		err = newOpenError(C.GoString(C.sf_strerror(o.s)), o)
 	}
 	*info = fromCinfo(ci)
 	o.Format = *info
@@ -230,6 +234,10 @@ func (f *File) Close() (err error) {
 		nf := os.NewFile(f.fd, "")
 		err = nf.Close()
 	}
+	if f.virtual != nil {
+		unregisterVirtual(f.virtual.handle)
+		f.virtual.handle = nil
+	}
 	runtime.SetFinalizer(f, nil)
 	return
 }
@@ -360,6 +368,51 @@ const (
 	Last        StringType = C.SF_STR_LAST
 )
 
+// stringTypes lists the string ids understood by GetString and SetString, in id order.
+var stringTypes = []StringType{Title, Copyright, Software, Artist, Comment, Date, Album, License}
+
+var stringTypeNames = map[StringType]string{
+	Title:     "title",
+	Copyright: "copyright",
+	Software:  "software",
+	Artist:    "artist",
+	Comment:   "comment",
+	Date:      "date",
+	Album:     "album",
+	License:   "license",
+}
+
+// String returns the lower case name of the string id, e.g. "title".
+func (t StringType) String() string {
+	if n, ok := stringTypeNames[t]; ok {
+		return n
+	}
+	return "string" + strconv.Itoa(int(t))
+}
+
+// MarshalText lets StringType be used as a readable JSON object key.
+func (t StringType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText is the inverse of MarshalText.
+func (t *StringType) UnmarshalText(b []byte) error {
+	for k, n := range stringTypeNames {
+		if n == string(b) {
+			*t = k
+			return nil
+		}
+	}
+	if strings.HasPrefix(string(b), "string") {
+		i, err := strconv.Atoi(string(b[len("string"):]))
+		if err == nil {
+			*t = StringType(i)
+			return nil
+		}
+	}
+	return errors.New("unknown string type " + string(b))
+}
+
 //The GetString() method returns the specified string if it exists and a NULL pointer otherwise. In addition to the string ids above, First (== Title) and Last (always the same as the highest numbers string id) are also available to allow iteration over all the available string ids.
 func (f *File) GetString(typ StringType) (out string) {
 	// although it's not clear from the docs, sf_get_string doesn't require you to free the string that is returned
@@ -387,6 +440,9 @@ func (f *File) SetString(in string, typ StringType) (err error) {
 //Returns the number of items written (which should be the same as the length of the input parameter). err will be nil, except in case of failure
 
 func (f *File) WriteItems(in interface{}) (written int64, err error) {
+	if d := f.ditherInts(in, false); d != nil {
+		in = d
+	}
 	t := reflect.TypeOf(in)
 	if t.Kind() != reflect.Array && t.Kind() != reflect.Slice {
 		errors.New("You need to give me an array!")
@@ -424,6 +480,12 @@ func (f *File) WriteItems(in interface{}) (written int64, err error) {
 	}
 
 	written = int64(n)
+	if f.dither != nil {
+		f.dither.wrote(written)
+	}
+	if f.meter != nil && n > 0 {
+		f.meter.addItems(in, int(n), f.meterScale(in))
+	}
 	if int(n) != l {
 		err = errors.New(C.GoString(C.sf_strerror(f.s)))
 	}
@@ -436,6 +498,9 @@ func (f *File) WriteItems(in interface{}) (written int64, err error) {
 //
 //Returns the number of frames written (which should be the same as the length of the input parameter divided by the number of channels). err wil be nil except in case of failure
 func (f *File) WriteFrames(in interface{}) (written int64, err error) {
+	if d := f.ditherInts(in, true); d != nil {
+		in = d
+	}
 	t := reflect.TypeOf(in)
 	if t.Kind() != reflect.Array && t.Kind() != reflect.Slice {
 		errors.New("You need to give me an array!")
@@ -478,6 +543,12 @@ func (f *File) WriteFrames(in interface{}) (written int64, err error) {
 	}
 
 	written = int64(n)
+	if f.dither != nil {
+		f.dither.wrote(written * int64(f.Format.Channels))
+	}
+	if f.meter != nil && n > 0 {
+		f.meter.addItems(in, int(n)*int(f.Format.Channels), f.meterScale(in))
+	}
 	if int(n) != frames {
 		err = errors.New(C.GoString(C.sf_strerror(f.s)))
 	}
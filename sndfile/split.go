@@ -0,0 +1,145 @@
+package sndfile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SplitOptions chooses where Split cuts a file. The cuts from every way that is set are combined; at least one must be set.
+type SplitOptions struct {
+	SilenceDB  float64         // if not zero, cut in the middle of every run of silence below this level in dBFS, except at the ends of the file
+	MinSilence time.Duration   // shortest run of silence to cut at, 500 ms if zero
+	Cues       bool            // cut at every cue point, at its sample offset
+	Times      []time.Duration // cut at these offsets from the start of the file
+	Interval   time.Duration   // cut at every multiple of this length
+}
+
+// A SplitPart is one of the files written by Split.
+type SplitPart struct {
+	Path  string
+	Index int // 0 for the first part
+	FrameRange
+}
+
+// Split cuts the file at src into parts, written in the same format to files named by replacing {seq} in template with the part index, zero padded to four digits. Each part carries the strings of src, and its broadcast extension with the time reference advanced to the start of the part; cue points are carried into the part they fall in. Audio is copied as int32 between integer formats, so the parts join up into exactly the source.
+//
+// If splitting fails or ctx is cancelled, the parts already written are removed.
+func Split(ctx context.Context, src, template string, opts SplitOptions) (parts []SplitPart, err error) {
+	if !strings.Contains(template, "{seq}") {
+		return nil, errors.New("Split: template needs {seq}")
+	}
+	var si Info
+	in, err := Open(src, Read, &si)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	cuts, err := splitPoints(in, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			for _, p := range parts {
+				os.Remove(p.Path)
+			}
+			parts = nil
+		}
+	}()
+	start := int64(0)
+	for _, end := range append(cuts, si.Frames) {
+		p := SplitPart{
+			Path:       strings.Replace(template, "{seq}", fmt.Sprintf("%04d", len(parts)), -1),
+			Index:      len(parts),
+			FrameRange: FrameRange{start, end},
+		}
+		if err = writePart(ctx, in, p); err != nil {
+			os.Remove(p.Path)
+			return parts, err
+		}
+		parts = append(parts, p)
+		start = end
+	}
+	return parts, nil
+}
+
+// splitPoints returns the frames of f at which opts cuts it, in order, without repeats and strictly inside the file.
+func splitPoints(f *File, opts SplitOptions) ([]int64, error) {
+	frames, rate := f.Format.Frames, f.Format.Samplerate
+	var cuts []int64
+	set := false
+	if opts.SilenceDB != 0 {
+		min := opts.MinSilence
+		if min == 0 {
+			min = 500 * time.Millisecond
+		}
+		silence, err := DetectSilence(f, opts.SilenceDB, min)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range silence {
+			if r.Start > 0 && r.End < frames {
+				cuts = append(cuts, r.Start+r.Frames()/2)
+			}
+		}
+		set = true
+	}
+	if opts.Cues {
+		cues, _ := f.GetCues()
+		// the sample offset is where a cue is, as in copyExcerptMetadata; AIFF markers have no position
+		for _, c := range cues {
+			cuts = append(cuts, int64(c.SampleOffset))
+		}
+		set = true
+	}
+	for _, t := range opts.Times {
+		cuts = append(cuts, durationFrames(t, rate))
+		set = true
+	}
+	if opts.Interval > 0 {
+		step := durationFrames(opts.Interval, rate)
+		if step <= 0 {
+			return nil, errors.New("Split: interval shorter than a frame")
+		}
+		for n := step; n < frames; n += step {
+			cuts = append(cuts, n)
+		}
+		set = true
+	}
+	if !set {
+		return nil, errors.New("Split: no way to split chosen")
+	}
+
+	sort.Slice(cuts, func(i, j int) bool { return cuts[i] < cuts[j] })
+	kept := cuts[:0]
+	for _, c := range cuts {
+		if c > 0 && c < frames && (len(kept) == 0 || c != kept[len(kept)-1]) {
+			kept = append(kept, c)
+		}
+	}
+	return kept, nil
+}
+
+// writePart writes the frames of src in p's range to a new file at p.Path in the same format.
+func writePart(ctx context.Context, src *File, p SplitPart) (err error) {
+	info := src.Format
+	out, err := Open(p.Path, Write, &info)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	if err = copyExcerptMetadata(out, src, p.FrameRange); err != nil {
+		return err
+	}
+	return copyRange(ctx, out, src, p.FrameRange, nil)
+}
@@ -0,0 +1,96 @@
+package sndfile
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSplit(t *testing.T) {
+	// a second of tone, a 300 ms gap and another second of tone, with silence at the ends that isn't cut at
+	a := make([]float64, 0, 2*158400)
+	a = append(a, make([]float64, 2*24000)...)
+	a = append(a, tone(48000, 2, -20, 0, 1)...)
+	a = append(a, make([]float64, 2*14400)...)
+	a = append(a, tone(48000, 2, -20, 1)...)
+	a = append(a, make([]float64, 2*24000)...)
+	i := Info{Samplerate: 48000, Channels: 2, Format: SF_FORMAT_WAV | SF_FORMAT_PCM_16}
+	writeFloats(t, "splitsrc.wav", i, a, func(f *File) {
+		f.SetString("session", Title)
+		f.SetBroadcastInfo(&BroadcastInfo{Description: "gaps", Time_reference_low: 1000})
+		f.SetCues([]CuePoint{{Index: 1, Position: 86400, FccChunk: 0x61746164, SampleOffset: 86400}})
+	})
+	f, err := Open("splitsrc.wav", Read, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := make([]int32, 2*i.Frames)
+	f.ReadFrames(src)
+	f.Close()
+
+	parts, err := Split(context.Background(), "splitsrc.wav", "split{seq}.wav", SplitOptions{SilenceDB: -60, MinSilence: 200 * time.Millisecond, Times: []time.Duration{time.Second}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []FrameRange{{0, 48000}, {48000, 79200}, {79200, 158400}}
+	if len(parts) != len(want) {
+		t.Fatalf("split into %+v, expected %v", parts, want)
+	}
+	var joined []int32
+	for j, p := range parts {
+		if p.FrameRange != want[j] || p.Index != j || p.Path != fmt.Sprintf("split%04d.wav", j) {
+			t.Errorf("part %+v, expected %v", p, want[j])
+		}
+		f, err := Open(p.Path, Read, &i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := make([]int32, 2*i.Frames)
+		f.ReadFrames(b)
+		joined = append(joined, b...)
+		bi, ok := f.GetBroadcastInfo()
+		if !ok || bi.Time_reference_low != 1000+uint32(p.Start) || f.GetString(Title) != "session" {
+			t.Errorf("part %d has broadcast extension %+v and title %q", j, bi, f.GetString(Title))
+		}
+		cues, ok := f.GetCues()
+		if j == 2 && (!ok || len(cues) != 1 || cues[0].Position != 86400-79200) || j != 2 && ok {
+			t.Errorf("part %d has cues %+v", j, cues)
+		}
+		f.Close()
+	}
+	if len(joined) != len(src) {
+		t.Fatalf("parts have %d samples, expected %d", len(joined), len(src))
+	}
+	for j := range src {
+		if joined[j] != src[j] {
+			t.Fatalf("sample %d of the parts is %d, expected %d", j, joined[j], src[j])
+		}
+	}
+
+	// cue points and fixed intervals, with the cut they share made once
+	parts, err = Split(context.Background(), "splitsrc.wav", "split{seq}.wav", SplitOptions{Cues: true, Interval: 1800 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 2 || parts[0].FrameRange != (FrameRange{0, 86400}) || parts[1].FrameRange != (FrameRange{86400, 158400}) {
+		t.Errorf("split into %+v", parts)
+	}
+
+	if _, err = Split(context.Background(), "splitsrc.wav", "split{seq}.wav", SplitOptions{}); err == nil {
+		t.Error("expected an error with no way to split")
+	}
+
+	// AIFF markers come back with only a sample offset
+	ai := Info{Samplerate: 48000, Channels: 2, Format: SF_FORMAT_AIFF | SF_FORMAT_PCM_16}
+	writeFloats(t, "splitsrc.aiff", ai, a, func(f *File) {
+		f.SetCues([]CuePoint{{Index: 1, Position: 86400, FccChunk: 0x61746164, SampleOffset: 86400}})
+	})
+	parts, err = Split(context.Background(), "splitsrc.aiff", "split{seq}.aiff", SplitOptions{Cues: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 2 || parts[0].FrameRange != (FrameRange{0, 86400}) || parts[1].FrameRange != (FrameRange{86400, 158400}) {
+		t.Errorf("split aiff into %+v", parts)
+	}
+}
@@ -0,0 +1,148 @@
+package sndfile
+
+import (
+	"context"
+	"math"
+	"math/bits"
+	"time"
+)
+
+// SignalStats describes the samples of a channel, or of all channels together, normalised to [-1.0, 1.0]. Levels are linear rather than in dB so that silence, whose level in dB is -Inf, can still be encoded as JSON.
+type SignalStats struct {
+	Samples          int64   `json:"samples"`
+	Min              float64 `json:"min"`
+	Max              float64 `json:"max"`
+	Peak             float64 `json:"peak"` // the larger of -Min and Max
+	RMS              float64 `json:"rms"`
+	DCOffset         float64 `json:"dc_offset"`          // the mean of the samples
+	CrestFactor      float64 `json:"crest_factor"`       // Peak divided by RMS, 0 for silence
+	ZeroCrossings    int64   `json:"zero_crossings"`     // changes of sign from one sample to the next, counting 0 as positive
+	ZeroCrossingRate float64 `json:"zero_crossing_rate"` // zero crossings per second of each channel
+	Clipped          int64   `json:"clipped"`            // samples at or beyond the largest value the format can hold
+	BitDepth         int     `json:"bit_depth"`          // bits the samples actually use: 16 for 16 bit audio padded out to 24, up to 32
+}
+
+// Stats is a report on the audio in a file, in the manner of sox's stat and stats effects.
+type Stats struct {
+	Frames   int64         `json:"frames"`
+	Duration time.Duration `json:"duration"`
+	Channels []SignalStats `json:"channels"`
+	Overall  SignalStats   `json:"overall"`
+}
+
+// signalAccumulator gathers a SignalStats a sample at a time.
+type signalAccumulator struct {
+	n        int64
+	min, max float64
+	sum, sq  float64
+	prev     float64
+	cross    int64
+	clipped  int64
+	used     uint64 // the bits used by any sample, scaled to the top of 32
+	inexact  bool   // some sample needs more than 32 bits
+}
+
+func newSignalAccumulator() *signalAccumulator {
+	return &signalAccumulator{min: math.Inf(1), max: math.Inf(-1)}
+}
+
+func (a *signalAccumulator) add(s, clip float64) {
+	if a.n > 0 && (a.prev < 0) != (s < 0) {
+		a.cross++
+	}
+	a.prev = s
+	a.n++
+	a.min = math.Min(a.min, s)
+	a.max = math.Max(a.max, s)
+	a.sum += s
+	a.sq += s * s
+	if s >= clip || s <= -clip {
+		a.clipped++
+	}
+	v := s * (1 << 31)
+	if v != math.Trunc(v) {
+		a.inexact = true
+	} else {
+		a.used |= uint64(int64(v))
+	}
+}
+
+// merge adds b's samples to a, for the overall figures. Zero crossings between the two are not counted.
+func (a *signalAccumulator) merge(b *signalAccumulator) {
+	a.n += b.n
+	a.min = math.Min(a.min, b.min)
+	a.max = math.Max(a.max, b.max)
+	a.sum += b.sum
+	a.sq += b.sq
+	a.cross += b.cross
+	a.clipped += b.clipped
+	a.used |= b.used
+	a.inexact = a.inexact || b.inexact
+}
+
+// stats returns the figures for the samples so far, with the zero crossing rate over seconds of audio in each of channels.
+func (a *signalAccumulator) stats(seconds float64, channels int) SignalStats {
+	s := SignalStats{Samples: a.n, ZeroCrossings: a.cross, Clipped: a.clipped}
+	if a.n == 0 {
+		return s
+	}
+	s.Min, s.Max = a.min, a.max
+	s.Peak = math.Max(-a.min, a.max)
+	s.RMS = math.Sqrt(a.sq / float64(a.n))
+	s.DCOffset = a.sum / float64(a.n)
+	if s.RMS > 0 {
+		s.CrestFactor = s.Peak / s.RMS
+	}
+	if seconds > 0 {
+		s.ZeroCrossingRate = float64(a.cross) / float64(channels) / seconds
+	}
+	switch {
+	case a.inexact:
+		s.BitDepth = 32
+	case a.used != 0:
+		s.BitDepth = 32 - bits.TrailingZeros64(a.used)
+	}
+	return s
+}
+
+// clipLevel returns the normalised value at which a format's samples are clipped: the largest integer it can hold, or 1.0 for float and compressed formats.
+func clipLevel(f Format) float64 {
+	b := ditherBits(f)
+	if b == 0 {
+		return 1
+	}
+	full := math.Ldexp(1, int(b)-1)
+	return (full - 1) / full
+}
+
+// Stats reads the whole file, which must be open for reading, and reports on its samples, per channel and overall. The read position is restored afterwards. If ctx is cancelled the report stops with its error.
+func (f *File) Stats(ctx context.Context) (*Stats, error) {
+	channels := int(f.Format.Channels)
+	acc := make([]*signalAccumulator, channels)
+	for c := range acc {
+		acc[c] = newSignalAccumulator()
+	}
+	clip := clipLevel(f.Format.Format)
+	err := f.readAll(ctx, func(b []float64) {
+		for i, s := range b {
+			acc[i%channels].add(s, clip)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	st := &Stats{Frames: acc[0].n, Channels: make([]SignalStats, channels)}
+	var seconds float64
+	if f.Format.Samplerate > 0 {
+		seconds = float64(st.Frames) / float64(f.Format.Samplerate)
+		st.Duration = time.Duration(seconds * float64(time.Second))
+	}
+	all := newSignalAccumulator()
+	for c, a := range acc {
+		st.Channels[c] = a.stats(seconds, 1)
+		all.merge(a)
+	}
+	st.Overall = all.stats(seconds, channels)
+	return st, nil
+}
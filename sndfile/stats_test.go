@@ -0,0 +1,84 @@
+package sndfile
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	const frames = 1000
+	in := make([]int32, 2*frames)
+	for n := 0; n < frames; n++ {
+		in[2*n] = 16384 << 16
+		if n%2 == 1 {
+			in[2*n] = -16384 << 16
+		}
+		in[2*n+1] = 8192 << 16
+	}
+	in[2*10+1], in[2*11+1], in[2*12+1] = 32767<<16, 32767<<16, -32768<<16
+
+	// the 16 bit full scale samples only count as clipped in a 16 bit file
+	for format, clipped := range map[Format]int64{SF_FORMAT_PCM_16: 3, SF_FORMAT_PCM_24: 1} {
+		i := Info{Samplerate: 1000, Channels: 2, Format: SF_FORMAT_WAV | format}
+		f, err := Open("stats.wav", Write, &i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteFrames(in)
+		f.Close()
+		if f, err = Open("stats.wav", Read, &i); err != nil {
+			t.Fatal(err)
+		}
+		st, err := f.Stats(context.Background())
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if st.Frames != frames || st.Duration.Seconds() != 1 || len(st.Channels) != 2 {
+			t.Fatalf("%#x: unexpected report %+v", format, st)
+		}
+		sq := st.Channels[0]
+		if sq.Min != -0.5 || sq.Max != 0.5 || sq.Peak != 0.5 || sq.RMS != 0.5 || sq.DCOffset != 0 || sq.CrestFactor != 1 {
+			t.Errorf("%#x: square wave levels %+v", format, sq)
+		}
+		if sq.ZeroCrossings != frames-1 || sq.ZeroCrossingRate != frames-1 || sq.Clipped != 0 {
+			t.Errorf("%#x: square wave crossings and clipping %+v", format, sq)
+		}
+		dc := st.Channels[1]
+		if dc.ZeroCrossings != 2 || dc.Clipped != clipped || math.Abs(dc.DCOffset-0.25) > 0.01 {
+			t.Errorf("%#x: offset channel %+v", format, dc)
+		}
+		if sq.BitDepth != 2 || dc.BitDepth != 16 || st.Overall.BitDepth != 16 {
+			t.Errorf("%#x: bit depths %d, %d and %d, expected 2, 16 and 16", format, sq.BitDepth, dc.BitDepth, st.Overall.BitDepth)
+		}
+		if o := st.Overall; o.Samples != 2*frames || o.Min != -1 || o.Clipped != clipped || o.ZeroCrossings != frames+1 {
+			t.Errorf("%#x: overall %+v", format, o)
+		}
+	}
+
+	// silence has no crest factor, and nothing in the report that JSON can't hold
+	i := Info{Samplerate: 1000, Channels: 1, Format: SF_FORMAT_WAV | SF_FORMAT_FLOAT}
+	f, err := Open("stats.wav", Write, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteFrames(make([]float32, 100))
+	f.Close()
+	if f, err = Open("stats.wav", Read, &i); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	st, err := f.Stats(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Overall.CrestFactor != 0 || st.Overall.BitDepth != 0 {
+		t.Errorf("silence reported as %+v", st.Overall)
+	}
+	if _, err = json.Marshal(st); err != nil {
+		t.Error("couldn't encode report", err)
+	}
+}
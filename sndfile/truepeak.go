@@ -0,0 +1,125 @@
+package sndfile
+
+import (
+	"context"
+	"math"
+)
+
+// The true peak oversampler: 4 times, with a Kaiser windowed sinc reaching 8 input samples each side. Its cutoff is the input's Nyquist frequency, so that the first of every 4 output samples is the input sample itself and the true peak is never below the sample peak.
+const (
+	truePeakFactor = 4
+	truePeakHalf   = 8
+	truePeakBeta   = 7
+)
+
+// truePeakTaps[p] is the filter for the output sample p quarters of a sample after an input sample, over the 2*truePeakHalf input samples around it, oldest first.
+var truePeakTaps = func() (t [truePeakFactor][2 * truePeakHalf]float64) {
+	i0beta := besselI0(truePeakBeta)
+	for p := range t {
+		for j := range t[p] {
+			x := float64(truePeakHalf-1-j) + float64(p)/truePeakFactor
+			u := x / truePeakHalf
+			if u*u >= 1 {
+				continue
+			}
+			t[p][j] = sinc(x) * besselI0(truePeakBeta*math.Sqrt(1-u*u)) / i0beta
+		}
+	}
+	return
+}()
+
+// A truePeakMeter finds the largest absolute value of each channel of interleaved audio after oversampling, and the frame nearest to it.
+type truePeakMeter struct {
+	channels int
+	hist     [][2 * truePeakHalf]float64 // the latest input samples of each channel, oldest first
+	frames   int64                       // input frames seen
+	peak     []float64
+	at       []int64
+}
+
+func newTruePeakMeter(channels int) *truePeakMeter {
+	return &truePeakMeter{
+		channels: channels,
+		hist:     make([][2 * truePeakHalf]float64, channels),
+		peak:     make([]float64, channels),
+		at:       make([]int64, channels),
+	}
+}
+
+// add takes interleaved frames.
+func (m *truePeakMeter) add(frames []float64) {
+	for i := 0; i+m.channels <= len(frames); i += m.channels {
+		for c := 0; c < m.channels; c++ {
+			m.push(c, frames[i+c])
+		}
+		m.frames++
+	}
+}
+
+// push adds a sample to a channel's history and measures the output samples between the two input samples at the middle of it, which lag the input by truePeakHalf frames. It returns the largest of them.
+func (m *truePeakMeter) push(c int, s float64) (max float64) {
+	h := &m.hist[c]
+	copy(h[:], h[1:])
+	h[len(h)-1] = s
+	centre := m.frames - truePeakHalf
+	if centre < 0 {
+		return 0
+	}
+	for p := range truePeakTaps {
+		var y float64
+		for j, w := range truePeakTaps[p] {
+			y += w * h[j]
+		}
+		y = math.Abs(y)
+		max = math.Max(max, y)
+		if y > m.peak[c] {
+			m.peak[c] = y
+			m.at[c] = centre
+			if p > truePeakFactor/2 {
+				m.at[c]++
+			}
+		}
+	}
+	return max
+}
+
+// peaks returns the true peak of each channel and the frame nearest to it, taking the audio to be followed by silence. The meter itself is left as it is, so that more audio can be added.
+func (m *truePeakMeter) peaks() (peak []float64, at []int64) {
+	tail := &truePeakMeter{
+		channels: m.channels,
+		hist:     append([][2 * truePeakHalf]float64(nil), m.hist...),
+		frames:   m.frames,
+		peak:     append([]float64(nil), m.peak...),
+		at:       append([]int64(nil), m.at...),
+	}
+	// the last input frames are only at the middle of the history after this many more
+	if m.frames > 0 {
+		tail.add(make([]float64, truePeakHalf*m.channels))
+	}
+	for c := range tail.at {
+		if tail.at[c] >= m.frames && m.frames > 0 {
+			tail.at[c] = m.frames - 1
+		}
+	}
+	return tail.peak, tail.at
+}
+
+// dbtp returns the peaks in dBTP.
+func (m *truePeakMeter) dbtp() (dbtp []float64, at []int64) {
+	peak, at := m.peaks()
+	dbtp = make([]float64, len(peak))
+	for c, p := range peak {
+		dbtp[c] = 20 * math.Log10(p)
+	}
+	return dbtp, at
+}
+
+// CalcTruePeakAllChannels measures the true peak of each channel in dBTP, the largest value after oversampling 4 times as in ITU-R BS.1770 Annex 2, which catches the peaks between samples that CalcMaxAllChannels misses. It also returns the frame nearest to each peak. This involves reading through the whole file which can be slow on large files; the read position is restored afterwards. If ctx is cancelled the measurement stops with its error.
+func (f *File) CalcTruePeakAllChannels(ctx context.Context) (dbtp []float64, at []int64, err error) {
+	m := newTruePeakMeter(int(f.Format.Channels))
+	if err = f.readAll(ctx, m.add); err != nil {
+		return nil, nil, err
+	}
+	dbtp, at = m.dbtp()
+	return dbtp, at, nil
+}
@@ -0,0 +1,64 @@
+package sndfile
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestCalcTruePeakAllChannels(t *testing.T) {
+	// a quarter of the sample rate at 45° is sampled only at 0.707 of its peak
+	const frames = 48000
+	out := make([]float64, 2*frames)
+	for n := 0; n < frames; n++ {
+		fade := 1.0
+		if n < 1000 {
+			fade = 0.5 - 0.5*math.Cos(math.Pi*float64(n)/1000)
+		} else if n >= frames-1000 {
+			fade = 0.5 - 0.5*math.Cos(math.Pi*float64(frames-1-n)/1000)
+		}
+		out[2*n] = 0.5 * fade * math.Sin(math.Pi/2*float64(n)+math.Pi/4)
+	}
+	out[2*5000+1] = 0.25
+	i := Info{Samplerate: 48000, Channels: 2, Format: SF_FORMAT_WAV | SF_FORMAT_FLOAT}
+	f, err := Open("truepeak.wav", Write, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteFrames(out)
+	f.Close()
+
+	f, err = Open("truepeak.wav", Read, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.Seek(10, Set)
+	dbtp, at, err := f.CalcTruePeakAllChannels(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos, _ := f.Seek(0, Current); pos != 10 {
+		t.Errorf("read position left at %d, expected 10", pos)
+	}
+	sample, _ := f.CalcNormMaxAllChannels()
+	if s := 20 * math.Log10(sample[0]); math.Abs(s+9.03) > 0.1 {
+		t.Errorf("sample peak %g dBFS, expected -9.03", s)
+	}
+	if math.Abs(dbtp[0]+6.02) > 0.2 {
+		t.Errorf("true peak %g dBTP, expected -6.02", dbtp[0])
+	}
+	if at[0] < 1000 || at[0] >= frames-1000 {
+		t.Errorf("true peak at frame %d, expected it away from the fades", at[0])
+	}
+	// an impulse's true peak is the impulse itself
+	if math.Abs(dbtp[1]-20*math.Log10(0.25)) > 1e-9 || at[1] != 5000 {
+		t.Errorf("impulse true peak %g dBTP at %d, expected %g at 5000", dbtp[1], at[1], 20*math.Log10(0.25))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err = f.CalcTruePeakAllChannels(ctx); err != context.Canceled {
+		t.Errorf("expected cancellation, got %v", err)
+	}
+}
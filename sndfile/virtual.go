@@ -1,12 +1,13 @@
 package sndfile
 
+// #include <stdlib.h>
 // #include <sndfile.h>
 // #include "virtual.h"
 import "C"
 //import "fmt"
 import "runtime"
 import "unsafe"
-import "errors"
+import "sync"
 
 type VIO_get_filelen func(interface{}) int64
 type VIO_seek func(int64, Whence, interface{}) int64
@@ -18,18 +19,20 @@ type VIO_tell func(interface{}) int64
 // THIS PART OF THE PACKAGE IS EXPERIMENTAL. Don't use it yet.
 func OpenVirtual(v VirtualIo, mode Mode, info *Info) (f *File, err error) {
 	c := C.virtualio()
-	var vp virtualIo
+	vp := new(virtualIo)
 	vp.v = &v
 	vp.c = c
+	vp.handle = registerVirtual(vp)
 	f = new(File)
 	ci := info.toCinfo()
-	f.s = C.sf_open_virtual(c, C.int(mode), ci, unsafe.Pointer(&vp))
+	f.s = C.sf_open_virtual(c, C.int(mode), ci, vp.handle)
 	if f.s != nil {
-		f.virtual = &vp
+		f.virtual = vp
 		f.Format = fromCinfo(ci)
 		*info = f.Format
 	} else {
-		err = errors.New(C.GoString(C.sf_strerror(nil)))
+		err = newOpenError(C.GoString(C.sf_strerror(nil)), f)
+		unregisterVirtual(vp.handle)
 	}
 	runtime.SetFinalizer(f, (*File).Close)
 	return
@@ -52,13 +55,44 @@ type VirtualIo struct {
 }
 
 type virtualIo struct {
-	v *VirtualIo
-	c *C.SF_VIRTUAL_IO
+	v      *VirtualIo
+	c      *C.SF_VIRTUAL_IO
+	handle unsafe.Pointer
+}
+
+// cgo doesn't allow handing Go pointers to C to hang on to, so libsndfile gets a small C allocation as its user_data and the callbacks look the Go side up here.
+var virtuals = struct {
+	sync.Mutex
+	m map[unsafe.Pointer]*virtualIo
+}{m: make(map[unsafe.Pointer]*virtualIo)}
+
+func registerVirtual(vp *virtualIo) unsafe.Pointer {
+	h := C.malloc(1)
+	virtuals.Lock()
+	virtuals.m[h] = vp
+	virtuals.Unlock()
+	return h
+}
+
+func unregisterVirtual(h unsafe.Pointer) {
+	if h == nil {
+		return
+	}
+	virtuals.Lock()
+	delete(virtuals.m, h)
+	virtuals.Unlock()
+	C.free(h)
+}
+
+func lookupVirtual(h unsafe.Pointer) *virtualIo {
+	virtuals.Lock()
+	defer virtuals.Unlock()
+	return virtuals.m[h]
 }
 
 //export gsfLen
 func gsfLen(user_data unsafe.Pointer) int64 {
-	l := (*virtualIo)(user_data)
+	l := lookupVirtual(user_data)
 	return l.v.GetLength(l.v.UserData)
 }
 
@@ -67,26 +101,26 @@ func gsfSeek(i int64, w Whence, user_data unsafe.Pointer) int64 {
 	if user_data == nil {
 		panic("nil ud")
 	}
-	l := (*virtualIo)(user_data)
+	l := lookupVirtual(user_data)
 	return l.v.Seek(i, w, l.v.UserData)
 }
 
 //export gsfRead
 func gsfRead(ptr unsafe.Pointer, i int64, user_data unsafe.Pointer) int64 {
-	l := (*virtualIo)(user_data)
+	l := lookupVirtual(user_data)
 	b := (*[1 << 30]byte)(ptr)[0:i]
 	return l.v.Read(b, l.v.UserData)
 }
 
 //export gsfWrite
 func gsfWrite(ptr unsafe.Pointer, i int64, user_data unsafe.Pointer) int64 {
-	l := (*virtualIo)(user_data)
+	l := lookupVirtual(user_data)
 	b := (*[1 << 30]byte)(ptr)[0:i]
 	return l.v.Write(b, l.v.UserData)
 }
 
 //export gsfTell
 func gsfTell(user_data unsafe.Pointer) int64 {
-	l := (*virtualIo)(user_data)
+	l := lookupVirtual(user_data)
 	return l.v.Tell(l.v.UserData)
 }